@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/brickster241/rest-go/internal/repository/sqlconnect"
+)
+
+// Handles `api migrate up|down [steps]|status`, DB must already be connected.
+func runMigrateCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatalln("Usage : migrate up | down [steps] | status")
+	}
+
+	switch args[0] {
+	case "up":
+		count, err := sqlconnect.MigrateUp()
+		if err != nil {
+			log.Fatalln("Migration failed... :", err)
+		}
+		log.Printf("Applied %d migration(s).\n", count)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalln("Steps should be a positive number.")
+			}
+			steps = n
+		}
+		count, err := sqlconnect.MigrateDown(steps)
+		if err != nil {
+			log.Fatalln("Rollback failed... :", err)
+		}
+		log.Printf("Rolled back %d migration(s).\n", count)
+	case "status":
+		statuses, err := sqlconnect.GetMigrationStatus()
+		if err != nil {
+			log.Fatalln("Couldn't fetch migration status... :", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied at " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q. Usage : migrate up | down [steps] | status\n", args[0])
+	}
+}
@@ -8,8 +8,13 @@ import (
 	"os"
 	"time"
 
+	"github.com/brickster241/rest-go/internal/api/handlers"
 	mw "github.com/brickster241/rest-go/internal/api/middlewares"
 	"github.com/brickster241/rest-go/internal/api/router"
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository"
+	"github.com/brickster241/rest-go/internal/repository/memory"
+	"github.com/brickster241/rest-go/internal/repository/sqlconnect"
 	"github.com/brickster241/rest-go/pkg/utils"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -55,6 +60,28 @@ func loadEnvFromEmbeddedFile() {
 	}
 }
 
+// The in-memory store starts empty, so create an admin from ADMIN_USERNAME / ADMIN_PASSWORD to be able to login.
+func seedMemoryAdmin(repos repository.Repositories) {
+	username := os.Getenv("ADMIN_USERNAME")
+	password := os.Getenv("ADMIN_PASSWORD")
+	if username == "" || password == "" {
+		log.Println("ADMIN_USERNAME / ADMIN_PASSWORD not set, in-memory store has no execs.")
+		return
+	}
+
+	_, err := repos.Execs.PostExecs([]models.Exec{{
+		FirstName: "Admin",
+		LastName:  "User",
+		Email:     username + "@school.com",
+		Username:  username,
+		Password:  password,
+		Role:      "admin",
+	}})
+	if err != nil {
+		log.Fatalf("Error seeding in-memory admin : %v", err)
+	}
+}
+
 func main() {
 	// Only in development for running source code.
 	loadEnvFromEmbeddedFile()
@@ -62,13 +89,50 @@ func main() {
 	cert := os.Getenv("CERT_FILE")
 	key := os.Getenv("KEY_FILE")
 
+	// REPOSITORY=memory runs the whole API without Postgres.
+	var repos repository.Repositories
+	if os.Getenv("REPOSITORY") == "memory" {
+		repos = memory.NewRepositories()
+		seedMemoryAdmin(repos)
+	} else {
+		// Shared DB connection pool, fails fast if DB is down.
+		db, err := sqlconnect.ConnectDB(sqlconnect.LoadDBConfig())
+		if err != nil {
+			log.Fatalln("Couldn't connect to DB... :", err)
+		}
+		defer db.Close()
+		sqlconnect.SetDB(db)
+
+		// `go run ./cmd/api migrate up|down|status` runs migrations and exits.
+		if len(os.Args) > 1 && os.Args[1] == "migrate" {
+			runMigrateCommand(os.Args[2:])
+			return
+		}
+
+		// DB_AUTO_MIGRATE=true applies pending migrations on startup.
+		if os.Getenv("DB_AUTO_MIGRATE") == "true" {
+			count, err := sqlconnect.MigrateUp()
+			if err != nil {
+				log.Fatalln("Couldn't migrate DB... :", err)
+			}
+			log.Printf("Applied %d migration(s).\n", count)
+		}
+		repos = sqlconnect.NewRepositories()
+	}
+	handlers.SetRepositories(repos)
+
+	// JWT_ALGORITHM picks HS256 with JWT_SECRET, or RS256/EdDSA with the keys in JWT_KEYS_DIR.
+	err := utils.LoadJWTKeys()
+	if err != nil {
+		log.Fatalln("Couldn't load JWT keys... :", err)
+	}
 	
 	rl := mw.NewRateLimiter(5, time.Minute)
 	hppOptions := mw.HPPOptions{
 		CheckQuery: true,
 		CheckBody: true,
 		CheckBodyOnlyForContentType: "application/x-www-form-urlencoded",
-		WhiteList: []string{"sortBy", "sortOrder", "name", "age", "class"},
+		WhiteList: []string{"q", "sortby", "page", "limit", "cursor", "id", "first_name", "last_name", "email", "class", "subject", "username", "inactive_status", "role", "created_at", "user_created_at"},
 	}
 
 	tlsConfig := &tls.Config{
@@ -76,8 +140,16 @@ func main() {
 	}
 
 	// Proper Middleware order.
-	jwt_MW := mw.ExcludePathsMW(mw.JWT_MW, "/execs/login", "/execs/forgotpassword", "/execs/resetpassword/reset")
-	secureMux := utils.ApplyMiddleWares(router.MainRouter(), mw.Hpp(hppOptions), mw.SecurityHeadersMW, mw.CompressionMW, jwt_MW, mw.XSS_MW, mw.ResponseTimeMW, rl.RateLimiterMW, mw.CorsMW)
+	// JWT_TOKEN_PRECEDENCE=cookie prefers the "Bearer" cookie over the Authorization header.
+	jwtOptions := mw.JWTOptions{
+		TokenSources: []string{mw.TokenFromHeader, mw.TokenFromCookie},
+	}
+	if os.Getenv("JWT_TOKEN_PRECEDENCE") == "cookie" {
+		jwtOptions.TokenSources = []string{mw.TokenFromCookie, mw.TokenFromHeader}
+	}
+	jwtAuth := mw.NewJWTAuth(repos.Execs, repos.RevokedTokens, repos.APIKeys, repos.Sessions, jwtOptions)
+	jwt_MW := mw.ExcludePathsMW(jwtAuth.JWT_MW, "/execs/login", "/execs/refresh", "/execs/logout", "/execs/forgotpassword", "/execs/resetpassword/reset", "/execs/invite/accept", "/.well-known/jwks.json")
+	secureMux := utils.ApplyMiddleWares(router.MainRouter(), mw.Hpp(hppOptions), mw.SecurityHeadersMW, mw.CompressionMW, jwt_MW, mw.XSS_MW, mw.ResponseTimeMW, rl.RateLimiterMW, mw.CorsMW, mw.RequestIDMW)
 	// Define Port and Start server
 	port := ":3000"
 
@@ -89,7 +161,7 @@ func main() {
 	}
 
 	log.Printf("Server running on Port %v\n", port)
-	err := server.ListenAndServeTLS(cert, key)
+	err = server.ListenAndServeTLS(cert, key)
 	if err != nil {
 		log.Fatalln("Couldn't start server... :", err)
 	}
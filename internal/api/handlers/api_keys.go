@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// GET /apikeys
+func GetAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := apiKeyRepo.GetAPIKeys()
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	resp := struct {
+		Status string          `json:"status"`
+		Count  int             `json:"count"`
+		Data   []models.APIKey `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(keys),
+		Data:   keys,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /apikeys
+func PostAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.APIKeyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if req.Role == "" {
+		req.Role = "service"
+	}
+	expiresAt, err := validateAPIKeyRequest(req)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	apiKey, prefix, keyHash, err := utils.GenerateAPIKey()
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Could not create API key. Internal error.").Error())
+		return
+	}
+	key, err := apiKeyRepo.CreateAPIKey(models.APIKey{
+		Name:      req.Name,
+		Role:      req.Role,
+		Scopes:    req.Scopes,
+		Prefix:    prefix,
+		KeyHash:   keyHash,
+		ExpiresAt: sql.NullString{String: expiresAt.UTC().Format(time.RFC3339Nano), Valid: true},
+		CreatedBy: currentExecID(r),
+	})
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.APIKeyCreatedResponse{APIKey: key, Key: apiKey})
+}
+
+// DELETE /apikeys/{id}
+func DeleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid API key ID.").Error())
+		return
+	}
+
+	err = apiKeyRepo.DeleteAPIKey(keyId)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Checks the request tags, that every scope is granted to the role, and returns the expiry.
+// The self service scopes need a logged in exec, so keys can't hold them.
+func validateAPIKeyRequest(req models.APIKeyRequest) (time.Time, error) {
+	var errs utils.ValidationErrors
+	err := utils.ValidateStruct(req)
+	if err != nil {
+		errs = append(errs, err.(utils.ValidationErrors)...)
+	}
+
+	for _, scope := range req.Scopes {
+		permission := utils.Permission(scope)
+		if permission == utils.PermExecsSelf || permission == utils.PermExecsPassword || !utils.HasPermission(req.Role, permission) {
+			errs = append(errs, utils.FieldError{Field: "scopes", Message: fmt.Sprintf("%s can't be granted to keys with the %s role", scope, req.Role)})
+		}
+	}
+
+	duration, err := utils.APIKeyDuration()
+	if err != nil {
+		return time.Time{}, utils.ErrorHandler(err, "Invalid API_KEY_EXPIRES.")
+	}
+	expiresAt := time.Now().Add(duration)
+	if req.ExpiresAt != "" {
+		expiresAt, err = time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			errs = append(errs, utils.FieldError{Field: "expires_at", Message: "must be an RFC 3339 timestamp"})
+		} else if !expiresAt.After(time.Now()) {
+			errs = append(errs, utils.FieldError{Field: "expires_at", Message: "must be in the future"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return time.Time{}, errs
+	}
+	return expiresAt, nil
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/go-mail/mail/v2"
+)
+
+// Sends a plain text email from the school admin address through the local mail server.
+func sendEmail(to, subject, body string) error {
+	m := mail.NewMessage()
+	m.SetHeader("From", "schooladmin@school.com")
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/plain", body)
+	d := mail.NewDialer("localhost", 1025, "", "")
+	return d.DialAndSend(m)
+}
+
+// Same as sendEmail for notices the request doesn't wait on, failures are only logged.
+func sendEmailAsync(to, subject, body string) {
+	go func() {
+		err := sendEmail(to, subject, body)
+		if err != nil {
+			log.Printf("Failed to send %q email to %s: %v\n", subject, to, err)
+		}
+	}()
+}
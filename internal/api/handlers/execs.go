@@ -1,22 +1,21 @@
 package handlers
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	models "github.com/brickster241/rest-go/internal/models"
-	"github.com/brickster241/rest-go/internal/repository/sqlconnect"
+	"github.com/brickster241/rest-go/internal/repository"
 	"github.com/brickster241/rest-go/pkg/utils"
-	"github.com/go-mail/mail/v2"
 )
 
 var mu_exec = &sync.Mutex{}
@@ -24,43 +23,59 @@ var mu_exec = &sync.Mutex{}
 // GET execs/
 func GetExecsHandler(w http.ResponseWriter, r *http.Request) {
 
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
+	
+	page, limit := utils.GetPaginationParams(r)
+	// Filter based on different params, sorting will be of type param:asc or param:desc
+	filters, err := addQueryFiltersExec(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	
-	query := "SELECT id, first_name, last_name, email, username, user_created_at, inactive_status, role FROM execs WHERE 1=1"
-	var args []interface{}
-	
-	// Filter based on different params
-	query, args = addQueryFiltersExec(r, query, args)
-
-	// Will be of type param:asc or param:desc
-	query = applySortingFiltersExec(r, query)
+	sortFields := applySortingFiltersExec(r)
+	opts := repository.ListOptions{
+		Filters: filters,
+		Sort:    sortFields,
+		Page:    page,
+		Limit:   limit,
+		Search:  utils.SearchTerms(r.URL.Query().Get("q")),
+	}
 
-	page, limit := utils.GetPaginationParams(r)
-	offset := (page - 1) * limit
-	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	// ?cursor= lists the rows after the cursor, one extra row tells if there is a next page.
+	cursor, cursorMode, err := getCursor(r, sortFields)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "Invalid cursor.")
+		return
+	}
+	if cursorMode {
+		page = 0
+		opts.Page, opts.Limit, opts.After = 1, limit+1, cursor
+	}
 
 	// Connect to DB
-	execList, totalExecs, err := sqlconnect.GetExecsDBHandler(query, args)
+	execList, totalExecs, err := execRepo.GetExecs(opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
+	execList, nextCursor := keysetPage(execList, limit, !cursorMode && page*limit < totalExecs, sortFields)
+	pageInfo := utils.GetPageInfo(r, page, limit, totalExecs, nextCursor)
+	if cursorMode {
+		pageInfo = utils.GetCursorPageInfo(r, limit, cursor != nil, totalExecs, nextCursor)
+	}
 
 	resp := struct {
 		Status string    `json:"status"`
 		Count  int       `json:"count"`
-		Page int `json:"page"`
+		Page int `json:"page,omitempty"`
 		PageSize int `json:"page_size"`
+		utils.PageInfo
 		Data   []models.Exec `json:"data"`
 	}{
 		Status: "success",
 		Count:  totalExecs,
 		Page: page,
 		PageSize : limit, 
+		PageInfo: pageInfo,
 		Data:   execList,
 	}
 
@@ -68,27 +83,20 @@ func GetExecsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// GET /execs/{id}
+// GET /execs/{id}, GET /execs/me
 func GetOneExecHandler(w http.ResponseWriter, r *http.Request) {
 
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	
-	idStr := r.PathValue("id")
-	// Handle Path Parameters
-	execId, err := strconv.Atoi(idStr)
+	// Handle Path Parameters, /execs/me reads the logged in exec
+	execId, err := targetExecID(r)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Exec ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Exec ID.").Error())
 		return
 	}
 
 	// Connect to DB
-	exec, err := sqlconnect.GetOneExecDBHandler(execId)
+	exec, err := execRepo.GetOneExec(execId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -96,31 +104,22 @@ func GetOneExecHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(exec)
 }
 
-func applySortingFiltersExec(r *http.Request, query string) string {
+func applySortingFiltersExec(r *http.Request) []repository.SortField {
 	sortParams := r.URL.Query()["sortby"]
-	addQuery := " ORDER BY"
-	if len(sortParams) > 0 {
-		for i, sortParam := range sortParams {
-			parts := strings.Split(sortParam, ":")
-			if len(parts) != 2 {
-				continue
-			}
-
-			field, order := parts[0], parts[1]
-			if !isValidOrder(order) || !isValidSortFieldExec(field) {
-				continue
-			}
-			// To ensure to incorporate multiple sorting values
-			if i > 0 {
-				addQuery += ","
-			}
-			addQuery += fmt.Sprintf(" %s %s", field, order)
+	var sortFields []repository.SortField
+	for _, sortParam := range sortParams {
+		parts := strings.Split(sortParam, ":")
+		if len(parts) != 2 {
+			continue
 		}
-		if addQuery != " ORDER BY" {
-			query += addQuery
+
+		field, order := parts[0], parts[1]
+		if !isValidOrder(order) || !isValidSortFieldExec(field) {
+			continue
 		}
+		sortFields = append(sortFields, repository.SortField{Field: field, Order: order})
 	}
-	return query
+	return sortFields
 }
 
 func isValidSortFieldExec(field string) bool {
@@ -132,35 +131,25 @@ func isValidSortFieldExec(field string) bool {
 	return validFields[field]
 }
 
-func addQueryFiltersExec(r *http.Request, query string, args []interface{}) (string, []interface{}) {
-	params := []string{
-		"first_name",
-		"last_name",
-		"email",
-		"username",
-		"inactive_status",
-		"role",
-	}
-
-	for _, param := range params {
-		value := r.URL.Query().Get(param)
-		if value != "" {
-			query += fmt.Sprintf(" AND %s=$%d", param, len(args)+1)
-			args = append(args, value)
-		}
-	}
-	return query, args
+// Filterable fields and their types, see parseQueryFilters.
+var execFilterFields = map[string]string{
+	"id":              filterInt,
+	"first_name":      filterString,
+	"last_name":       filterString,
+	"email":           filterString,
+	"username":        filterString,
+	"inactive_status": filterBool,
+	"role":            filterString,
+	"user_created_at": filterTime,
+}
+
+func addQueryFiltersExec(r *http.Request) ([]repository.Filter, error) {
+	return parseQueryFilters(r, execFilterFields)
 }
 
 // POST /execs/
 func PostExecsHandler(w http.ResponseWriter, r *http.Request) {
 
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_exec.Lock()
 	defer mu_exec.Unlock()
@@ -170,14 +159,14 @@ func PostExecsHandler(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Error reading Request Body.", http.StatusInternalServerError)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, "Error reading Request Body.")
 		return
 	}
 	defer r.Body.Close()
 
 	err = json.Unmarshal(body, &rawExecs)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Request Body.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
 		return
 	}
 
@@ -191,7 +180,7 @@ func PostExecsHandler(w http.ResponseWriter, r *http.Request) {
 		for key := range exec {
 			_, ok := allowedFields[key]
 			if !ok {
-				http.Error(w, "Unacceptable Field found in request.", http.StatusBadRequest)
+				utils.WriteProblem(w, r, http.StatusBadRequest, "Unacceptable Field found in request.")
 				return
 			}
 		}
@@ -199,24 +188,42 @@ func PostExecsHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = json.Unmarshal(body, &newExecs)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Request Body.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
 		return
 	}
 	
-	// Check whether all fields are non empty.
-	for _, exec := range newExecs {
-		err := utils.CheckBlankFields(exec)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	// Validate every record, so the client sees all the failing records and fields at once.
+	errs, _ := utils.ValidateItems(newExecs).(utils.ValidationErrors)
+	policy := utils.CurrentPasswordPolicy()
+	for i, exec := range newExecs {
+		// Execs without a password are invited to choose one.
+		if exec.Password != "" {
+			index := i
+			errs = append(errs, policy.Validate("password", &index, exec.Password, exec.Username, exec.Email)...)
 		}
 	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return *errs[i].Index < *errs[j].Index })
+		utils.WriteError(w, r, errs)
+		return
+	}
+
+	inviteTokens, err := prepareInvites(newExecs)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Error Adding Execs.").Error())
+		return
+	}
 
 	// Connect to DB
-	addedExecs, err := sqlconnect.PostExecsDBHandler(newExecs)
+	addedExecs, err := execRepo.PostExecs(newExecs)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
+	for i, token := range inviteTokens {
+		expiresAt, _ := time.Parse(time.RFC3339Nano, addedExecs[i].InviteTokenExpires.String)
+		sendInviteEmail(addedExecs[i], token, expiresAt)
+	}
 	
 	// Set the Headers
 	w.Header().Set("Content-Type", "application/json")
@@ -233,24 +240,16 @@ func PostExecsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// PATCH /execs/{id}
+// PATCH /execs/{id}, PATCH /execs/me
 func PatchOneExecHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_exec.Lock()
 	defer mu_exec.Unlock()
 
-	idStr := r.PathValue("id")
-
-	// Handle Path Parameters
-	execId, err := strconv.Atoi(idStr)
+	// Handle Path Parameters, /execs/me patches the logged in exec
+	execId, err := targetExecID(r)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Exec ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Exec ID.").Error())
 		return
 	}
 
@@ -258,14 +257,26 @@ func PatchOneExecHandler(w http.ResponseWriter, r *http.Request) {
 	var updates map[string]interface{}
 	err = json.NewDecoder(r.Body).Decode(&updates)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Payload Request.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Payload Request.").Error())
+		return
+	}
+
+	err = authorizeExecAccess(r, execId, updates)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	err = utils.ValidateUpdates(models.Exec{}, updates)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// Connect to DB
-	existingExec, err := sqlconnect.PatchOneExecDBHandler(execId, updates)
+	existingExec, err := execRepo.PatchOneExec(execId, updates)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -276,27 +287,42 @@ func PatchOneExecHandler(w http.ResponseWriter, r *http.Request) {
 
 // PATCH /execs/{id}
 func PatchExecsHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_exec.Lock()
 	defer mu_exec.Unlock()
 
 	// Get specific patch keys
 	var updates []map[string]interface{}
-	err = json.NewDecoder(r.Body).Decode(&updates)
+	err := json.NewDecoder(r.Body).Decode(&updates)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Payload Request.").Error())
+		return
+	}
+
+	err = utils.ValidateBulkUpdates(models.Exec{}, updates)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Payload Request.").Error(), http.StatusBadRequest)
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	existingExecs, err := sqlconnect.PatchExecsDBHandler(updates)
+	// Every record is checked, non-admins can only include their own.
+	for _, update := range updates {
+		execIdStr, _ := update["id"].(string)
+		execId, err := strconv.Atoi(execIdStr)
+		if err != nil {
+			utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Exec ID.").Error())
+			return
+		}
+		err = authorizeExecAccess(r, execId, update)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+	}
+
+	existingExecs, err := execRepo.PatchExecs(updates)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -309,12 +335,6 @@ func PatchExecsHandler(w http.ResponseWriter, r *http.Request) {
 // DELETE /execs/{id}
 func DeleteOneExecHandler(w http.ResponseWriter, r *http.Request) {
 	
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_exec.Lock()
 	defer mu_exec.Unlock()
@@ -324,14 +344,14 @@ func DeleteOneExecHandler(w http.ResponseWriter, r *http.Request) {
 	// Handle Path Parameters
 	execId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid exec ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid exec ID.").Error())
 		return
 	}
 
 	// Connect to DB
-	err = sqlconnect.DeleteOneExecDBHandler(execId)
+	err = execRepo.DeleteOneExec(execId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -354,61 +374,408 @@ func LoginExecHandler(w http.ResponseWriter, r *http.Request) {
 	// Data Validation
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Request Body.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
 		return
 	}
 
 	defer r.Body.Close()
 	
 	if req.Username == "" || req.Password == "" {
-		http.Error(w, utils.ErrorHandler(errors.New("username/password cannot be empty"), "username/password cannot be empty").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(errors.New("username/password cannot be empty"), "username/password cannot be empty").Error())
+		return
+	}
+
+	// Brute-force protection, failed logins are tracked per client IP and per username.
+	ip := clientIP(r)
+	now := time.Now()
+	wait := loginAttemptsByIP.retryAfter(ip, now)
+	if wait > 0 {
+		writeRetryAfter(w, r, http.StatusTooManyRequests, wait, "Too many failed logins from this address, try again later.")
 		return
 	}
 
 	// Search for user if user actually exists
-	exec, err := sqlconnect.LoginExecDBHandler(req)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	exec, err := execRepo.LoginExec(req)
+	if errors.Is(err, utils.ErrUnauthorized) {
+		loginAttemptsByIP.fail(ip, now)
+		utils.WriteError(w, r, err)
+		return
+	} else if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// Checked before the password, so a locked account can't be probed.
+	wait, locked := execLoginRetryAfter(exec, now)
+	if locked {
+		writeRetryAfter(w, r, http.StatusLocked, wait, "Account is temporarily locked after too many failed logins.")
+		return
+	} else if wait > 0 {
+		writeRetryAfter(w, r, http.StatusTooManyRequests, wait, "Too many failed logins, try again later.")
 		return
 	}
 
 	// Verify Password
 	err = utils.VerifyPassword(exec.Password, req.Password)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		lockout, failErr := recordFailedLogin(exec, ip, now)
+		if failErr != nil {
+			utils.WriteError(w, r, failErr)
+			return
+		} else if lockout > 0 {
+			writeRetryAfter(w, r, http.StatusLocked, lockout, "Account is temporarily locked after too many failed logins.")
+			return
+		}
+		utils.WriteError(w, r, err)
 		return
 	}
+	if exec.FailedLoginAttempts > 0 || exec.LockedUntil.Valid {
+		err = execRepo.ResetFailedLogins(exec.ID)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+	}
+
+	// Hashes made with older parameters are upgraded now that the password is known.
+	// Failing to do so doesn't fail the login, it is tried again next time.
+	if utils.PasswordNeedsRehash(exec.Password) {
+		newHash, err := utils.HashPassword(req.Password)
+		if err == nil {
+			err = execRepo.RehashPassword(exec.ID, exec.Password, newHash)
+		}
+		if err != nil {
+			log.Println("Couldn't rehash password :", err)
+		}
+	}
 
-	// Generate Token
-	tokenString, err := utils.SignToken(exec.ID, exec.Username, exec.Role)
+	completeLogin(w, r, exec, false)
+}
+
+// Ends a login once exec proved who they are, with a password, a magic link or a passkey.
+// multiFactor is set when that proof already was more than one factor.
+func completeLogin(w http.ResponseWriter, r *http.Request, exec models.Exec, multiFactor bool) {
+	// A second factor is needed when MFA is enabled, or is required for the role and
+	// has to be enrolled first. Tokens are issued by LoginMFAHandler then.
+	mfa, err := mfaRepo.GetMFA(exec.ID)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Could not create Login Token. Internal error.").Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
+		return
+	}
+	if !multiFactor && (mfa.Enabled || utils.MFARequired(exec.Role)) {
+		mfaToken, err := utils.SignMFAToken(exec.ID)
+		if err != nil {
+			utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Could not create MFA Token. Internal error.").Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(loginResponse{
+			MFARequired: true,
+			MFAEnrollmentRequired: !mfa.Enabled,
+			MFAToken: mfaToken,
+		})
 		return
 	}
 
-	// Send Token as a response or as a cookie
-	http.SetCookie(w, &http.Cookie{
-		Name: "Bearer",
-		Value: tokenString,
-		Path: "/",
-		HttpOnly: true,
-		Secure: true,
-		Expires: time.Now().Add(20 * time.Minute),
-		SameSite: http.SameSiteStrictMode,
-	})
+	resp, err := issueLoginTokens(w, r, exec)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
 
 	// Response Body
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Body of a login. Either the tokens, or the mfa token to send to /execs/login/mfa.
+type loginResponse struct {
+	Token string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired bool `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+	MFAToken string `json:"mfa_token,omitempty"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// Issues the access and refresh tokens of a completed login, also set as cookies.
+// Every login starts a new session, and its refresh token a new family.
+func issueLoginTokens(w http.ResponseWriter, r *http.Request, exec models.Exec) (loginResponse, error) {
+	refreshToken, refreshRecord, err := newRefreshToken()
+	if err != nil {
+		return loginResponse{}, utils.ErrorHandler(err, "Could not create Login Token. Internal error.")
+	}
+	session, err := startSession(r, exec.ID, refreshRecord.ExpiresAt)
+	if err != nil {
+		return loginResponse{}, err
+	}
+	refreshRecord.ExecID = exec.ID
+	refreshRecord.FamilyID = refreshRecord.TokenHash
+	refreshRecord.SessionID = session.ID
+	err = refreshTokenRepo.CreateRefreshToken(refreshRecord)
+	if err != nil {
+		return loginResponse{}, err
+	}
+
+	tokenString, err := setAccessToken(w, exec, session.ID)
+	if err != nil {
+		return loginResponse{}, utils.ErrorHandler(err, "Could not create Login Token. Internal error.")
+	}
+	setRefreshCookie(w, refreshToken, refreshRecord.ExpiresAt)
+
+	return loginResponse{
+		Token: tokenString,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// POST /execs/refresh
+func RefreshExecTokenHandler(w http.ResponseWriter, r *http.Request) {
+	// Browsers send the cookie, other clients the refresh_token from the login response.
+	var refreshToken string
+	cookie, err := r.Cookie(refreshCookieName)
+	if err == nil {
+		refreshToken = cookie.Value
+	} else {
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
+			return
+		}
+		refreshToken = req.RefreshToken
+	}
+	defer r.Body.Close()
+
+	if refreshToken == "" {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, "Refresh token missing.")
+		return
+	}
+	hashedToken, err := utils.HashToken(refreshToken)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, "Invalid refresh token.")
+		return
+	}
+
+	nextToken, nextRecord, err := newRefreshToken()
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Could not create Login Token. Internal error.").Error())
+		return
+	}
+	exec, sessionId, err := refreshTokenRepo.RotateRefreshToken(hashedToken, nextRecord)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	// Refresh tokens issued before sessions existed belong to none.
+	if sessionId == 0 {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, "Refresh token has no session, please log in again.")
+		return
+	}
+	// The session lives as long as its latest refresh token.
+	err = sessionRepo.TouchSession(sessionId, time.Now(), nextRecord.ExpiresAt)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	tokenString, err := setAccessToken(w, exec, sessionId)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Could not create Login Token. Internal error.").Error())
+		return
+	}
+	setRefreshCookie(w, nextToken, nextRecord.ExpiresAt)
+
 	w.Header().Set("Content-Type", "application/json")
 	resp := struct{
 		Token string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}{
 		Token: tokenString,
+		RefreshToken: nextToken,
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /execs/{id}/unlock
+func UnlockExecHandler(w http.ResponseWriter, r *http.Request) {
+	execId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Exec ID.").Error())
+		return
+	}
+
+	err = execRepo.ResetFailedLogins(execId)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Exec successfully unlocked.",
+		ID:     execId,
 	}
 	json.NewEncoder(w).Encode(resp)
 }
 
+// Fields only admins may change through the PATCH routes.
+var adminOnlyExecFields = []string{"role", "inactive_status"}
+
+// Id of the logged in exec, placed in the context by JWT_MW.
+func currentExecID(r *http.Request) int {
+	userId, _ := r.Context().Value(utils.ContextKey("userId")).(float64)
+	return int(userId)
+}
+
+// The exec a route acts on, the {id} path value or the logged in exec on /execs/me routes.
+func targetExecID(r *http.Request) (int, error) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		return currentExecID(r), nil
+	}
+	return strconv.Atoi(idStr)
+}
+
+// Self or admin: without execs:admin an exec can only act on its own account, and can't
+// change the fields in adminOnlyExecFields.
+func authorizeExecAccess(r *http.Request, execId int, updates map[string]interface{}) error {
+	role, _ := r.Context().Value(utils.ContextKey("role")).(string)
+	if utils.HasPermission(role, utils.PermExecsAdmin) {
+		return nil
+	}
+	if execId != currentExecID(r) {
+		return &utils.AppError{Kind: utils.ErrForbidden, Msg: "You can only manage your own account."}
+	}
+	for _, field := range adminOnlyExecFields {
+		if _, ok := updates[field]; ok {
+			return &utils.AppError{Kind: utils.ErrForbidden, Msg: fmt.Sprintf("Only admins can change %s.", field)}
+		}
+	}
+	return nil
+}
+
+// Reports whether password matches one of the hashes returned by GetPasswordHistory.
+func passwordReused(history []string, password string) bool {
+	for _, hash := range history {
+		if utils.VerifyPassword(hash, password) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Name of the refresh token cookie. It is scoped to /execs, the only place it is needed.
+const refreshCookieName = "RefreshToken"
+
+// Signs an access token for exec in the given session and sets it as the Bearer cookie,
+// expiring with the token.
+func setAccessToken(w http.ResponseWriter, exec models.Exec, sessionId int) (string, error) {
+	duration, err := utils.AccessTokenDuration()
+	if err != nil {
+		return "", err
+	}
+	token, err := utils.SignToken(exec.ID, exec.Username, exec.Role, sessionId)
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name: "Bearer",
+		Value: token,
+		Path: "/",
+		HttpOnly: true,
+		Secure: true,
+		Expires: time.Now().Add(duration),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
+
+func setRefreshCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name: refreshCookieName,
+		Value: token,
+		Path: "/execs",
+		HttpOnly: true,
+		Secure: true,
+		Expires: expiresAt,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// Generates a refresh token, along with the record to store, which only holds its hash.
+func newRefreshToken() (string, models.RefreshToken, error) {
+	duration, err := utils.RefreshTokenDuration()
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	token, hashedToken, err := utils.GenerateHashedToken()
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	return token, models.RefreshToken{TokenHash: hashedToken, ExpiresAt: time.Now().Add(duration)}, nil
+}
+
 // POST /execs/logout
+// Excluded from JWT_MW, so a client whose access token expired can still revoke its refresh token.
 func LogoutExecHandler(w http.ResponseWriter, r *http.Request) {
+	// Browsers send the cookie, other clients the refresh_token from the login response.
+	var refreshToken string
+	cookie, err := r.Cookie(refreshCookieName)
+	if err == nil {
+		refreshToken = cookie.Value
+	} else {
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
+			return
+		}
+		refreshToken = req.RefreshToken
+	}
+	defer r.Body.Close()
+
+	// Revoke the refresh token family of this login, an unknown token is already unusable.
+	if refreshToken != "" {
+		hashedToken, err := utils.HashToken(refreshToken)
+		if err == nil {
+			err = refreshTokenRepo.RevokeRefreshTokenFamily(hashedToken)
+			if err != nil && !errors.Is(err, utils.ErrNotFound) {
+				utils.WriteError(w, r, err)
+				return
+			}
+		}
+	}
+	setRefreshCookie(w, "", time.Unix(0, 0))
+
+	// Revoke the access token too, it is remembered until it would have expired. An expired
+	// or invalid one is already unusable.
+	claims, err := logoutAccessToken(r)
+	if err == nil {
+		err = revokedTokenRepo.RevokeToken(claims.ID, claims.UserID, claims.ExpiresAt)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+
+		// And end the session, so it no longer shows up in the sessions list.
+		if claims.SessionID != 0 {
+			err = sessionRepo.DeleteSession(claims.UserID, claims.SessionID)
+			if err != nil && !errors.Is(err, utils.ErrNotFound) {
+				utils.WriteError(w, r, err)
+				return
+			}
+		}
+	}
+
 	// Send Token as a response or as a cookie
 	http.SetCookie(w, &http.Cookie{
 		Name: "Bearer",
@@ -430,54 +797,97 @@ func LogoutExecHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// POST /execs/{id}/updatepassword
+// The login token sent with a logout, from the Authorization header or the Bearer cookie.
+func logoutAccessToken(r *http.Request) (utils.AccessTokenClaims, error) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if found && strings.EqualFold(scheme, "Bearer") && strings.TrimSpace(token) != "" {
+		return utils.ParseAccessToken(strings.TrimSpace(token))
+	}
+	cookie, err := r.Cookie("Bearer")
+	if err != nil {
+		return utils.AccessTokenClaims{}, err
+	}
+	return utils.ParseAccessToken(cookie.Value)
+}
+
+// POST /execs/{id}/updatepassword, POST /execs/me/updatepassword
 func UpdateExecPasswordHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	execId, err := strconv.Atoi(idStr)
+	execId, err := targetExecID(r)
 	if err != nil {
-		http.Error(w, "Invalid Exec Id.", http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, "Invalid Exec Id.")
+		return
+	}
+	err = authorizeExecAccess(r, execId, nil)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 	var req models.UpdatePasswordRequest
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Request Body.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.CurrentPassword == "" || req.NewPassword == "" {
-		http.Error(w, "please enter password", http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, "please enter password")
 		return
 	}
 
-	execName, execRole, err := sqlconnect.UpdateExecPasswordDBHandler(execId, req)
+	exec, err := execRepo.GetOneExec(execId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
-	
-	token, err := utils.SignToken(execId, execName, execRole)
+	policy := utils.CurrentPasswordPolicy()
+	errs := policy.Validate("new_password", nil, req.NewPassword, exec.Username, exec.Email)
+	if errs != nil {
+		utils.WriteError(w, r, errs)
+		return
+	}
+	history, err := execRepo.GetPasswordHistory(execId, policy.History)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Updated Password. Failed to Create Token.").Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
+		return
+	}
+	// The current password is checked first, so previous passwords can't be probed without it.
+	err = utils.VerifyPassword(history[0], req.CurrentPassword)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if passwordReused(history, req.NewPassword) {
+		utils.WriteError(w, r, policy.ReuseError("new_password"))
 		return
 	}
 
-	// Send Token as a response or as a cookie
-	http.SetCookie(w, &http.Cookie{
-		Name: "Bearer",
-		Value: token,
-		Path: "/",
-		HttpOnly: true,
-		Secure: true,
-		Expires: time.Now().Add(20 * time.Minute),
-		SameSite: http.SameSiteStrictMode,
-	})
+	_, _, err = execRepo.UpdateExecPassword(execId, req)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// An admin changing the password of another exec stays logged in as themselves.
+	if execId != currentExecID(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.UpdatePasswordResponse{PasswordUpdated: true})
+		return
+	}
+	
+	// The password change signed out every session, including their refresh tokens. The
+	// exec gets a fresh session, with its own refresh token, just like a login.
+	tokens, err := issueLoginTokens(w, r, exec)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
 
 	// Response Body
 	w.Header().Set("Content-Type", "application/json")
 	resp := models.UpdatePasswordResponse{
-		Token: token,
+		Token: tokens.Token,
+		RefreshToken: tokens.RefreshToken,
 		PasswordUpdated: true,
 	}
 	json.NewEncoder(w).Encode(resp)
@@ -492,27 +902,21 @@ func ForgotExecPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&req)
 	defer r.Body.Close()
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Request Body.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
 		return
 	}
-	mins, token, err := sqlconnect.ForgotExecPasswordDBHandler(req.Email)
+	mins, token, err := execRepo.ForgotExecPassword(req.Email)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// Send the reset email
 	resetURL := fmt.Sprintf("https://localhost:3000/execs/resetpassword/reset/%s", token)
 	msg := fmt.Sprintf("Forgot your password? Reset your password using following link: \n%s\n If you didn't request a password reset, please ignore this email. This link is only valid for %d mins.\n", resetURL, int(mins))
-	m := mail.NewMessage()
-	m.SetHeader("From", "schooladmin@school.com")
-	m.SetHeader("To", req.Email)
-	m.SetHeader("Subject", "Your password Reset Link")
-	m.SetBody("text/plain", msg)
-	d := mail.NewDialer("localhost", 1025, "", "")
-	err = d.DialAndSend(m)
+	err = sendEmail(req.Email, "Your password Reset Link", msg)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Failed to send password Reset Email.").Error(), http.StatusInternalServerError)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Failed to send password Reset Email.").Error())
 		return
 	}
 	// Respond with Success Message.
@@ -531,40 +935,58 @@ func ResetPasswordHandler(w http.ResponseWriter, r* http.Request) {
 	var req ResetPasswordRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Request Body.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
 		return
 	}
 	defer r.Body.Close()
 
 	if req.NewPassword == "" || req.ConfirmPassword == "" {
-		http.Error(w, "Passwords should not be blank.", http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, "Passwords should not be blank.")
 		return
 	}
 
 	if req.NewPassword != req.ConfirmPassword {
-		http.Error(w, "Passwords should match.", http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, "Passwords should match.")
 		return
 	}
 
-	bytes, err := hex.DecodeString(token)
+	hashedTokenString, err := utils.HashToken(token)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Internal Server Error.").Error(), http.StatusInternalServerError)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Invalid / Expired Reset Code.").Error())
 		return
 	}
 
-	hashedToken := sha256.Sum256(bytes)
-	hashedTokenString := hex.EncodeToString(hashedToken[:])
+	exec, err := execRepo.GetExecByResetToken(hashedTokenString)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	policy := utils.CurrentPasswordPolicy()
+	errs := policy.Validate("new_password", nil, req.NewPassword, exec.Username, exec.Email)
+	if errs != nil {
+		utils.WriteError(w, r, errs)
+		return
+	}
+	history, err := execRepo.GetPasswordHistory(exec.ID, policy.History)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if passwordReused(history, req.NewPassword) {
+		utils.WriteError(w, r, policy.ReuseError("new_password"))
+		return
+	}
 
 	// Hash the new Password
 	hashedPwd, err := utils.HashPassword(req.NewPassword)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Error Resetting Password.").Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	err = sqlconnect.ResetPasswordDBHandler(hashedTokenString, hashedPwd)
+	err = execRepo.ResetPassword(hashedTokenString, hashedPwd)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 	fmt.Fprintln(w, "Password Reset Successfully.")
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/repository"
+)
+
+// Types of the filterable columns, they decide the operators and values a filter accepts.
+const (
+	filterString = "string"
+	filterInt    = "int"
+	filterTime   = "time"
+	filterBool   = "bool"
+)
+
+var filterOperators = map[string][]string{
+	filterString: {repository.OpEq, repository.OpNe, repository.OpLike, repository.OpIn},
+	filterInt:    {repository.OpEq, repository.OpNe, repository.OpIn, repository.OpGt, repository.OpGte, repository.OpLt, repository.OpLte},
+	filterTime:   {repository.OpEq, repository.OpNe, repository.OpGt, repository.OpGte, repository.OpLt, repository.OpLte},
+	filterBool:   {repository.OpEq, repository.OpNe},
+}
+
+// Parses field=value and field[op]=value query params into filters on the whitelisted fields,
+// e.g. first_name[like]=Jo%, class[in]=9A,9B, id[gt]=10, created_at[gte]=2024-01-01 or
+// email[not_like]=%@school.com. Unknown plain params are ignored, a malformed filter is an error.
+func parseQueryFilters(r *http.Request, fields map[string]string) ([]repository.Filter, error) {
+	query := r.URL.Query()
+
+	// Sorted keys keep the filter and error order stable between requests.
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var filters []repository.Filter
+	for _, key := range keys {
+		field, operator, bracketed := strings.Cut(key, "[")
+		if !bracketed {
+			operator = repository.OpEq
+		} else if !strings.HasSuffix(operator, "]") {
+			return nil, fmt.Errorf("Malformed filter %s, expected field[operator].", key)
+		}
+		operator = strings.TrimSuffix(operator, "]")
+
+		fieldType, ok := fields[field]
+		if !ok {
+			// Plain params may be page, sortby etc., only field[op] is surely meant as a filter.
+			if bracketed {
+				return nil, fmt.Errorf("Filtering on %s is not supported.", field)
+			}
+			continue
+		}
+
+		operator, negate := strings.CutPrefix(operator, "not_")
+		if !slices.Contains(filterOperators[fieldType], operator) {
+			return nil, fmt.Errorf("Operator %s is not supported for %s.", operator, field)
+		}
+
+		for _, value := range query[key] {
+			// Blank equality params were always ignored, keep it that way.
+			if value == "" && !bracketed {
+				continue
+			}
+
+			rawValues := []string{value}
+			if operator == repository.OpIn {
+				rawValues = strings.Split(value, ",")
+			}
+			values := make([]string, len(rawValues))
+			for i, rawValue := range rawValues {
+				parsed, err := parseFilterValue(strings.TrimSpace(rawValue), fieldType)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid value %q for filter %s.", rawValue, key)
+				}
+				values[i] = parsed
+			}
+			filters = append(filters, repository.Filter{Field: field, Operator: operator, Values: values, Negate: negate})
+		}
+	}
+	return filters, nil
+}
+
+// Checks a filter value against the column type and normalizes it, timestamps become UTC RFC 3339.
+func parseFilterValue(value string, fieldType string) (string, error) {
+	if value == "" {
+		return "", fmt.Errorf("empty value")
+	}
+	switch fieldType {
+	case filterInt:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(number), nil
+	case filterBool:
+		boolean, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(boolean), nil
+	case filterTime:
+		timestamp, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			timestamp, err = time.Parse(time.DateOnly, value)
+		}
+		if err != nil {
+			return "", err
+		}
+		return timestamp.UTC().Format(time.RFC3339Nano), nil
+	}
+	return value, nil
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Generates an invitation code to email, along with its hash and expiry to store.
+func newInvite() (string, string, time.Time, error) {
+	duration, err := utils.InviteTokenDuration()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	token, hashedToken, err := utils.GenerateHashedToken()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return token, hashedToken, time.Now().Add(duration), nil
+}
+
+// Gives every exec without a password an invitation code, stored when the execs are added.
+// Returns the codes to email, by position in newExecs.
+func prepareInvites(newExecs []models.Exec) (map[int]string, error) {
+	tokens := make(map[int]string)
+	for i := range newExecs {
+		if newExecs[i].Password != "" {
+			newExecs[i].InviteToken = sql.NullString{}
+			newExecs[i].InviteTokenExpires = sql.NullString{}
+			continue
+		}
+		token, hashedToken, expiresAt, err := newInvite()
+		if err != nil {
+			return nil, err
+		}
+		newExecs[i].InviteToken = sql.NullString{String: hashedToken, Valid: true}
+		newExecs[i].InviteTokenExpires = sql.NullString{String: expiresAt.UTC().Format(time.RFC3339Nano), Valid: true}
+		tokens[i] = token
+	}
+	return tokens, nil
+}
+
+// Emails the invitation link in the background. A failed email is only logged,
+// an admin can send a new link with POST /execs/{id}/invite.
+func sendInviteEmail(exec models.Exec, token string, expiresAt time.Time) {
+	inviteURL := fmt.Sprintf("https://localhost:3000/execs/invite/accept/%s", token)
+	msg := fmt.Sprintf("Hi %s,\n\nAn account with the username %s was created for you. Choose your password using the following link: \n%s\nThis link can only be used once and is valid until %s.\n", exec.FirstName, exec.Username, inviteURL, expiresAt.UTC().Format(time.RFC1123))
+	sendEmailAsync(exec.Email, "You have been invited", msg)
+}
+
+// POST /execs/{id}/invite
+func ReissueExecInviteHandler(w http.ResponseWriter, r *http.Request) {
+	execId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Exec ID.").Error())
+		return
+	}
+
+	token, hashedToken, expiresAt, err := newInvite()
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Failed to send the invitation.").Error())
+		return
+	}
+
+	// Replacing the code invalidates any link sent before.
+	exec, err := execRepo.ReissueExecInvite(execId, hashedToken, expiresAt)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	sendInviteEmail(exec, token, expiresAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := struct {
+		Status    string    `json:"status"`
+		ID        int       `json:"id"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{
+		Status:    fmt.Sprintf("Invitation sent to %s.", exec.Email),
+		ID:        execId,
+		ExpiresAt: expiresAt.UTC(),
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /execs/invite/accept/{invitecode}
+func AcceptExecInviteHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		NewPassword     string `json:"new_password"`
+		ConfirmPassword string `json:"confirm_password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if req.NewPassword == "" || req.ConfirmPassword == "" {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "Passwords should not be blank.")
+		return
+	}
+	if req.NewPassword != req.ConfirmPassword {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "Passwords should match.")
+		return
+	}
+
+	hashedTokenString, err := utils.HashToken(r.PathValue("invitecode"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Invalid / Expired Invitation Code.").Error())
+		return
+	}
+	exec, err := execRepo.GetExecByInviteToken(hashedTokenString)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	errs := utils.CurrentPasswordPolicy().Validate("new_password", nil, req.NewPassword, exec.Username, exec.Email)
+	if errs != nil {
+		utils.WriteError(w, r, errs)
+		return
+	}
+
+	hashedPwd, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	err = execRepo.AcceptExecInvite(hashedTokenString, hashedPwd)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := struct {
+		Status   string `json:"status"`
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	}{
+		Status:   "Invitation accepted, you can now log in.",
+		ID:       exec.ID,
+		Username: exec.Username,
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Publishes the public keys login tokens are verified with, so other services don't need
+// the signing secret. Verifiers may cache it for a few minutes, rotation keeps old keys listed.
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	response := struct {
+		Keys []utils.JWK `json:"keys"`
+	}{
+		Keys: utils.JWKS(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(response)
+}
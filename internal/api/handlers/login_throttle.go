@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Failed logins per client IP. Kept in memory like the rate limiter, the per username
+// counters live on the exec record.
+type ipLoginTracker struct {
+	mu       sync.Mutex
+	attempts map[string]ipLoginAttempts
+}
+
+type ipLoginAttempts struct {
+	failures    int
+	lastFailure time.Time
+}
+
+var loginAttemptsByIP = &ipLoginTracker{attempts: make(map[string]ipLoginAttempts)}
+
+// How long ip has to wait before its next login attempt, 0 if it may try now.
+func (t *ipLoginTracker) retryAfter(ip string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	policy := utils.IPLoginPolicy()
+	attempts := t.attempts[ip]
+	wait := max(policy.Backoff(attempts.failures), policy.Lockout(attempts.failures))
+	return attempts.lastFailure.Add(wait).Sub(now)
+}
+
+func (t *ipLoginTracker) fail(ip string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Addresses quiet for longer than a lockout start over, which also bounds the map. Ones
+	// that were locked out are kept for MaxLockout, so their next failure escalates the lockout.
+	policy := utils.IPLoginPolicy()
+	for addr, attempts := range t.attempts {
+		keep := policy.LockoutDuration
+		if policy.Lockout(attempts.failures) > 0 {
+			keep = policy.MaxLockout
+		}
+		if now.Sub(attempts.lastFailure) > keep {
+			delete(t.attempts, addr)
+		}
+	}
+	attempts := t.attempts[ip]
+	attempts.failures++
+	attempts.lastFailure = now
+	t.attempts[ip] = attempts
+}
+
+// Client address without the port, so every connection from a host counts together.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// How long exec has to wait before its next login attempt, and whether it is locked out
+// rather than only backing off.
+func execLoginRetryAfter(exec models.Exec, now time.Time) (time.Duration, bool) {
+	if exec.LockedUntil.Valid {
+		lockedUntil, err := time.Parse(time.RFC3339Nano, exec.LockedUntil.String)
+		if err == nil && now.Before(lockedUntil) {
+			return lockedUntil.Sub(now), true
+		}
+	}
+	if exec.LastFailedLoginAt.Valid {
+		lastFailure, err := time.Parse(time.RFC3339Nano, exec.LastFailedLoginAt.String)
+		if err == nil {
+			wait := lastFailure.Add(utils.UsernameLoginPolicy().Backoff(exec.FailedLoginAttempts)).Sub(now)
+			return max(wait, 0), false
+		}
+	}
+	return 0, false
+}
+
+// Counts a wrong password for exec and ip, locking the account once the policy says so.
+// Returns the lockout started, 0 if none. The exec is told by email, so a lockout they
+// didn't cause doesn't go unnoticed.
+func recordFailedLogin(exec models.Exec, ip string, now time.Time) (time.Duration, error) {
+	loginAttemptsByIP.fail(ip, now)
+
+	// Same expiry as the per IP counters, failures spread over weeks don't add up to a lockout.
+	policy := utils.UsernameLoginPolicy()
+	keep := policy.LockoutDuration
+	if policy.Lockout(exec.FailedLoginAttempts) > 0 {
+		keep = policy.MaxLockout
+	}
+	failures, err := execRepo.RecordFailedLogin(exec.ID, now, now.Add(-keep))
+	if err != nil {
+		return 0, err
+	}
+	lockout := policy.Lockout(failures)
+	if lockout == 0 {
+		return 0, nil
+	}
+	err = execRepo.LockExec(exec.ID, now.Add(lockout))
+	if err != nil {
+		return 0, err
+	}
+
+	msg := fmt.Sprintf("Hi %s,\n\nYour account was locked for %s after %d failed login attempts, the last one from %s.\nIf this wasn't you, reset your password once the lock expires or contact an administrator.\n", exec.FirstName, lockout, failures, ip)
+	sendEmailAsync(exec.Email, "Your account has been locked", msg)
+	return lockout, nil
+}
+
+// 429 or 423 with a Retry-After header, in whole seconds rounded up.
+func writeRetryAfter(w http.ResponseWriter, r *http.Request, status int, wait time.Duration, detail string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	utils.WriteProblem(w, r, status, detail)
+}
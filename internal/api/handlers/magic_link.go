@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Magic links sent per email address, kept in memory like the failed logins per IP.
+type magicLinkTracker struct {
+	mu    sync.Mutex
+	sends map[string][]time.Time
+}
+
+var magicLinksByEmail = &magicLinkTracker{sends: make(map[string][]time.Time)}
+
+// Counts a link for email unless the limit is reached, in which case it returns how long
+// to wait for the oldest send to leave the window.
+func (t *magicLinkTracker) allow(email string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Sends older than the window no longer count, which also bounds the map.
+	limit := utils.MagicLinkSendLimit()
+	for addr, sends := range t.sends {
+		recent := sends[:0]
+		for _, sent := range sends {
+			if now.Sub(sent) < limit.Window {
+				recent = append(recent, sent)
+			}
+		}
+		if len(recent) == 0 {
+			delete(t.sends, addr)
+		} else {
+			t.sends[addr] = recent
+		}
+	}
+
+	sends := t.sends[email]
+	if len(sends) >= limit.Max {
+		return sends[len(sends)-limit.Max].Add(limit.Window).Sub(now)
+	}
+	t.sends[email] = append(sends, now)
+	return 0
+}
+
+// POST /execs/login/magic
+func RequestMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
+		return
+	}
+	defer r.Body.Close()
+
+	// Emails are matched case-insensitively, by the limit as well as the lookup.
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "Email should not be blank.")
+		return
+	}
+
+	// Counted whether or not the email belongs to an exec, so the limit reveals nothing.
+	wait := magicLinksByEmail.allow(email, time.Now())
+	if wait > 0 {
+		writeRetryAfter(w, r, http.StatusTooManyRequests, wait, "Too many login links requested for this email, try again later.")
+		return
+	}
+
+	duration, err := utils.MagicLinkDuration()
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Failed to send the login link.").Error())
+		return
+	}
+	token, hashedToken, err := utils.GenerateHashedToken()
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Failed to send the login link.").Error())
+		return
+	}
+
+	// Unknown and inactive accounts get the same response, without an email.
+	exec, err := execRepo.CreateMagicLink(email, hashedToken, time.Now().Add(duration))
+	if err != nil && !errors.Is(err, utils.ErrNotFound) && !errors.Is(err, utils.ErrForbidden) {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if err == nil {
+		loginURL := fmt.Sprintf("https://localhost:3000/execs/login/magic/%s", token)
+		msg := fmt.Sprintf("Hi %s,\n\nUse the following link to log in as %s: \n%s\nThis link can only be used once and is valid for %d mins. If you didn't request it, you can ignore this email.\n", exec.FirstName, exec.Username, loginURL, int(duration.Minutes()))
+		sendEmailAsync(exec.Email, "Your login link", msg)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	resp := struct {
+		Status string `json:"status"`
+	}{
+		Status: "If an account uses this email, a login link has been sent to it.",
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Page the emailed link opens. Mail scanners fetch links to check them, so the GET only
+// shows this form, and the link is used up by the POST it submits.
+var magicLinkPage = template.Must(template.New("magic_link").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Log in</title></head>
+<body>
+<form method="post" action="/execs/login/magic/{{.}}">
+<p>Continue to log in with this link. It can only be used once.</p>
+<button type="submit">Log in</button>
+</form>
+</body>
+</html>
+`))
+
+// GET /execs/login/magic/{code}
+func MagicLinkPageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err := magicLinkPage.Execute(w, r.PathValue("code"))
+	if err != nil {
+		log.Println("Couldn't render the magic link page :", err)
+	}
+}
+
+// POST /execs/login/magic/{code}
+func MagicLinkLoginHandler(w http.ResponseWriter, r *http.Request) {
+	hashedTokenString, err := utils.HashToken(r.PathValue("code"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Invalid / Expired Login Link.").Error())
+		return
+	}
+
+	exec, err := execRepo.ConsumeMagicLink(hashedTokenString)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// The link replaces the password only, MFA still applies.
+	completeLogin(w, r, exec, false)
+}
@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// GET /execs/me/mfa
+func GetMFAStatusHandler(w http.ResponseWriter, r *http.Request) {
+	mfa, err := mfaRepo.GetMFA(currentExecID(r))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	role, _ := r.Context().Value(utils.ContextKey("role")).(string)
+	w.Header().Set("Content-Type", "application/json")
+	resp := struct {
+		Enabled           bool `json:"enabled"`
+		Required          bool `json:"required"`
+		RecoveryCodesLeft int  `json:"recovery_codes_left"`
+	}{
+		Enabled:           mfa.Enabled,
+		Required:          utils.MFARequired(role),
+		RecoveryCodesLeft: mfa.RecoveryCodesLeft,
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /execs/me/mfa/enroll, POST /execs/login/mfa/enroll
+func EnrollMFAHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeMFARequest(r)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Logged in execs enroll themselves, the login route enrolls the exec of the mfa token.
+	execId := currentExecID(r)
+	loginRoute := execId == 0
+	if loginRoute {
+		claims, err := utils.ParseMFAToken(req.MFAToken)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		// Each enrollment uses up the mfa token and returns a new one for /execs/login/mfa,
+		// so a captured token can't keep replacing the pending secret.
+		err = revokedTokenRepo.UseToken(claims.ID, claims.UserID, claims.ExpiresAt)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		execId = claims.UserID
+	}
+
+	exec, err := execRepo.GetOneExec(execId)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Could not enroll MFA. Internal error.").Error())
+		return
+	}
+
+	// Fails with a conflict when MFA is already enabled, so a password alone can't replace the secret.
+	err = mfaRepo.SetMFASecret(execId, secret)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	resp := models.MFAEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(exec.Username, secret),
+	}
+	if loginRoute {
+		resp.MFAToken, err = utils.SignMFAToken(execId)
+		if err != nil {
+			utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Could not enroll MFA. Internal error.").Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /execs/me/mfa/verify
+func VerifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeMFARequest(r)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mfa, err := mfaRepo.GetMFA(currentExecID(r))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if mfa.Enabled {
+		utils.WriteProblem(w, r, http.StatusConflict, "MFA is already enabled.")
+		return
+	}
+
+	// The first code confirms the authenticator holds the secret, then MFA is turned on.
+	recoveryCodes, err := confirmMFAEnrollment(mfa, req.Code)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := struct {
+		Enabled       bool     `json:"enabled"`
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		Enabled:       true,
+		RecoveryCodes: recoveryCodes,
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /execs/login/mfa
+func LoginMFAHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeMFARequest(r)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	claims, err := utils.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	execId := claims.UserID
+	used, err := revokedTokenRepo.IsTokenRevoked(claims.ID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if used {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, "MFA token has already been used, please log in again.")
+		return
+	}
+
+	// Wrong codes count as failed logins, with the same backoff and lockout as passwords.
+	ip := clientIP(r)
+	now := time.Now()
+	wait := loginAttemptsByIP.retryAfter(ip, now)
+	if wait > 0 {
+		writeRetryAfter(w, r, http.StatusTooManyRequests, wait, "Too many failed logins from this address, try again later.")
+		return
+	}
+	exec, err := execRepo.GetOneExec(execId)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	wait, locked := execLoginRetryAfter(exec, now)
+	if locked {
+		writeRetryAfter(w, r, http.StatusLocked, wait, "Account is temporarily locked after too many failed logins.")
+		return
+	} else if wait > 0 {
+		writeRetryAfter(w, r, http.StatusTooManyRequests, wait, "Too many failed logins, try again later.")
+		return
+	}
+
+	mfa, err := mfaRepo.GetMFA(execId)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// MFA required for the role but not enrolled yet: the code confirms the secret from
+	// /execs/login/mfa/enroll, and the recovery codes are returned with the tokens.
+	var recoveryCodes []string
+	if mfa.Enabled {
+		err = verifySecondFactor(mfa, req)
+	} else if mfa.Secret == "" {
+		err = &utils.AppError{Kind: utils.ErrValidation, Msg: "MFA enrollment required, call /execs/login/mfa/enroll first."}
+	} else {
+		recoveryCodes, err = confirmMFAEnrollment(mfa, req.Code)
+	}
+	if errors.Is(err, utils.ErrUnauthorized) {
+		lockout, failErr := recordFailedLogin(exec, ip, now)
+		if failErr != nil {
+			utils.WriteError(w, r, failErr)
+			return
+		} else if lockout > 0 {
+			writeRetryAfter(w, r, http.StatusLocked, lockout, "Account is temporarily locked after too many failed logins.")
+			return
+		}
+		utils.WriteError(w, r, err)
+		return
+	} else if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// The mfa token is used up, so it can't start a second login.
+	err = revokedTokenRepo.UseToken(claims.ID, execId, claims.ExpiresAt)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if exec.FailedLoginAttempts > 0 || exec.LockedUntil.Valid {
+		err = execRepo.ResetFailedLogins(execId)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+	}
+	if exec.InactiveStatus {
+		utils.WriteProblem(w, r, http.StatusForbidden, "Account is inactive.")
+		return
+	}
+
+	resp, err := issueLoginTokens(w, r, exec)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	resp.RecoveryCodes = recoveryCodes
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /execs/me/mfa/disable
+func DisableMFAHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeMFARequest(r)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	role, _ := r.Context().Value(utils.ContextKey("role")).(string)
+	if utils.MFARequired(role) {
+		utils.WriteProblem(w, r, http.StatusForbidden, fmt.Sprintf("MFA is required for the %s role.", role))
+		return
+	}
+
+	mfa, err := mfaRepo.GetMFA(currentExecID(r))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if !mfa.Enabled {
+		utils.WriteProblem(w, r, http.StatusConflict, "MFA is not enabled.")
+		return
+	}
+	err = verifySecondFactor(mfa, req)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	err = mfaRepo.DisableMFA(mfa.ExecID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := struct {
+		Enabled bool `json:"enabled"`
+	}{
+		Enabled: false,
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /execs/me/mfa/recoverycodes
+func RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeMFARequest(r)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mfa, err := mfaRepo.GetMFA(currentExecID(r))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if !mfa.Enabled {
+		utils.WriteProblem(w, r, http.StatusConflict, "MFA is not enabled.")
+		return
+	}
+
+	// Only a TOTP code is accepted, a recovery code can't be used to mint new ones.
+	err = verifyTOTPCode(mfa, req.Code)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	recoveryCodes, hashes, err := utils.GenerateRecoveryCodes()
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrorHandler(err, "Could not create recovery codes. Internal error.").Error())
+		return
+	}
+	err = mfaRepo.ReplaceRecoveryCodes(mfa.ExecID, hashes)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: recoveryCodes,
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DELETE /execs/{id}/mfa
+// For a lost authenticator. If MFA is required for the role, the exec enrolls again on next login.
+func ResetMFAHandler(w http.ResponseWriter, r *http.Request) {
+	execId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Exec ID.").Error())
+		return
+	}
+
+	err = mfaRepo.DisableMFA(execId)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The body is optional on the enroll routes, so an empty one is not an error.
+func decodeMFARequest(r *http.Request) (models.MFARequest, error) {
+	defer r.Body.Close()
+	var req models.MFARequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return req, utils.ErrorHandler(err, "Invalid Request Body.")
+	}
+	return req, nil
+}
+
+// Verifies the first TOTP code of a pending enrollment, enables MFA and returns the recovery codes.
+func confirmMFAEnrollment(mfa models.ExecMFA, code string) ([]string, error) {
+	if mfa.Secret == "" {
+		return nil, &utils.AppError{Kind: utils.ErrValidation, Msg: "MFA enrollment not started."}
+	}
+	err := verifyTOTPCode(mfa, code)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, hashes, err := utils.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Could not create recovery codes. Internal error.")
+	}
+	err = mfaRepo.EnableMFA(mfa.ExecID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// Checks a TOTP code and consumes its time step, so it can't be replayed.
+func verifyTOTPCode(mfa models.ExecMFA, code string) error {
+	if code == "" {
+		return &utils.AppError{Kind: utils.ErrValidation, Msg: "MFA code is required."}
+	}
+	step, ok := utils.VerifyTOTP(mfa.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return &utils.AppError{Kind: utils.ErrUnauthorized, Msg: "Invalid MFA code."}
+	}
+	return mfaRepo.UseTOTPStep(mfa.ExecID, step)
+}
+
+// Second factor of an enabled MFA, a TOTP code or else an unused recovery code.
+func verifySecondFactor(mfa models.ExecMFA, req models.MFARequest) error {
+	if req.Code == "" && req.RecoveryCode != "" {
+		codeHash, err := utils.HashRecoveryCode(req.RecoveryCode)
+		if err != nil {
+			return &utils.AppError{Kind: utils.ErrUnauthorized, Msg: "Invalid recovery code."}
+		}
+		return mfaRepo.UseRecoveryCode(mfa.ExecID, codeHash)
+	}
+	return verifyTOTPCode(mfa, req.Code)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/brickster241/rest-go/internal/repository"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Identifies a sortby in a cursor, e.g. "last_name:asc,first_name:desc".
+func sortKey(sortFields []repository.SortField) string {
+	var parts []string
+	for _, sortField := range sortFields {
+		parts = append(parts, sortField.Field+":"+sortField.Order)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Reads the cursor query param. An empty ?cursor= starts cursor pagination from the first row,
+// the returned bool is false when the request uses page/limit.
+func getCursor(r *http.Request, sortFields []repository.SortField) (*repository.Cursor, bool, error) {
+	if !r.URL.Query().Has("cursor") {
+		return nil, false, nil
+	}
+	cursor := r.URL.Query().Get("cursor")
+	if cursor == "" {
+		return nil, true, nil
+	}
+	values, id, err := utils.DecodeCursor(cursor, sortKey(sortFields), len(sortFields))
+	if err != nil {
+		return nil, true, err
+	}
+	return &repository.Cursor{Values: values, ID: id}, true, nil
+}
+
+// Trims the extra row fetched in cursor mode, and returns the cursor pointing after the
+// last item when there is a next page.
+func keysetPage[T any](items []T, limit int, hasNext bool, sortFields []repository.SortField) ([]T, string) {
+	if len(items) > limit {
+		items = items[:limit]
+		hasNext = true
+	}
+	if !hasNext || len(items) == 0 {
+		return items, ""
+	}
+
+	last := items[len(items)-1]
+	var values []string
+	for _, sortField := range sortFields {
+		values = append(values, utils.GetColumnValue(last, sortField.Field))
+	}
+	id, _ := strconv.Atoi(utils.GetColumnValue(last, "id"))
+	return items, utils.EncodeCursor(sortKey(sortFields), values, id)
+}
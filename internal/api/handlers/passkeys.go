@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Options of navigator.credentials.create(), binary fields base64url encoded.
+type passkeyCreationOptions struct {
+	Challenge              string                     `json:"challenge"`
+	RP                     passkeyRP                  `json:"rp"`
+	User                   passkeyUser                `json:"user"`
+	PubKeyCredParams       []passkeyCredentialParam   `json:"pubKeyCredParams"`
+	Timeout                int64                      `json:"timeout"`
+	Attestation            string                     `json:"attestation"`
+	ExcludeCredentials     []passkeyDescriptor        `json:"excludeCredentials"`
+	AuthenticatorSelection passkeyAuthenticatorPolicy `json:"authenticatorSelection"`
+}
+
+// Options of navigator.credentials.get(). No credentials are listed, passkeys are
+// discoverable so the authenticator offers the ones it holds for the relying party.
+type passkeyRequestOptions struct {
+	Challenge        string              `json:"challenge"`
+	RPID             string              `json:"rpId"`
+	Timeout          int64               `json:"timeout"`
+	UserVerification string              `json:"userVerification"`
+	AllowCredentials []passkeyDescriptor `json:"allowCredentials"`
+}
+
+type passkeyRP struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type passkeyUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type passkeyCredentialParam struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type passkeyDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type passkeyAuthenticatorPolicy struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// User handle of an exec, stored by the authenticator and returned on logins.
+func passkeyUserHandle(execId int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(execId)))
+}
+
+// Starts a ceremony: stores a new challenge for it and returns the challenge.
+func beginPasskeyCeremony(execId int, ceremony string, timeout time.Duration) (string, error) {
+	challenge, err := utils.GenerateWebAuthnChallenge()
+	if err != nil {
+		return "", utils.ErrorHandler(err, "Could not create passkey challenge. Internal error.")
+	}
+	err = passkeyRepo.CreatePasskeyChallenge(models.PasskeyChallenge{
+		Challenge: challenge,
+		ExecID:    execId,
+		Ceremony:  ceremony,
+		ExpiresAt: time.Now().Add(timeout),
+	})
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// Decodes the client data of a ceremony and uses up the challenge it answers.
+func consumePasskeyChallenge(credential models.PasskeyCredential, ceremony string) ([]byte, models.PasskeyChallenge, error) {
+	if credential.Type != "public-key" {
+		return nil, models.PasskeyChallenge{}, &utils.AppError{Kind: utils.ErrValidation, Msg: "Credential type should be public-key."}
+	}
+	clientDataJSON, err := utils.DecodeBase64URL(credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, models.PasskeyChallenge{}, utils.TypedErrorHandler(err, utils.ErrValidation, "Invalid clientDataJSON.")
+	}
+	clientData, err := utils.ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, models.PasskeyChallenge{}, utils.TypedErrorHandler(err, utils.ErrValidation, "Invalid clientDataJSON.")
+	}
+	challenge, err := passkeyRepo.ConsumePasskeyChallenge(clientData.Challenge, ceremony)
+	if err != nil {
+		return nil, models.PasskeyChallenge{}, err
+	}
+	return clientDataJSON, challenge, nil
+}
+
+// POST /execs/me/passkeys/register/begin
+func BeginPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeMFARequest(r)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	exec, err := execRepo.GetOneExec(currentExecID(r))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// A verified passkey login skips TOTP, so with MFA enabled an access token alone can't add one.
+	mfa, err := mfaRepo.GetMFA(exec.ID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if mfa.Enabled {
+		err = verifySecondFactor(mfa, req)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+	}
+	passkeys, err := passkeyRepo.GetExecPasskeys(exec.ID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	config := utils.CurrentWebAuthnConfig()
+	challenge, err := beginPasskeyCeremony(exec.ID, models.PasskeyRegistration, config.Timeout)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	options := passkeyCreationOptions{
+		Challenge: challenge,
+		RP:        passkeyRP{ID: config.RPID, Name: config.RPName},
+		User: passkeyUser{
+			ID:          passkeyUserHandle(exec.ID),
+			Name:        exec.Username,
+			DisplayName: exec.FirstName + " " + exec.LastName,
+		},
+		Timeout:     config.Timeout.Milliseconds(),
+		Attestation: "none",
+		// The same authenticator can't register twice.
+		ExcludeCredentials: make([]passkeyDescriptor, 0, len(passkeys)),
+		AuthenticatorSelection: passkeyAuthenticatorPolicy{
+			ResidentKey:      "required",
+			UserVerification: "preferred",
+		},
+	}
+	for _, alg := range utils.SupportedCOSEAlgs {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, passkeyCredentialParam{Type: "public-key", Alg: alg})
+	}
+	for _, passkey := range passkeys {
+		options.ExcludeCredentials = append(options.ExcludeCredentials, passkeyDescriptor{Type: "public-key", ID: passkey.CredentialID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		PublicKey passkeyCreationOptions `json:"publicKey"`
+	}{options})
+}
+
+// POST /execs/me/passkeys/register/finish
+func FinishPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	var credential models.PasskeyCredential
+	err := json.NewDecoder(r.Body).Decode(&credential)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
+		return
+	}
+	defer r.Body.Close()
+
+	err = utils.ValidateStruct(credential)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	clientDataJSON, challenge, err := consumePasskeyChallenge(credential, models.PasskeyRegistration)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	execId := currentExecID(r)
+	if challenge.ExecID != execId {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, "Passkey challenge was issued to another exec.")
+		return
+	}
+
+	attestationObject, err := utils.DecodeBase64URL(credential.Response.AttestationObject)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid attestationObject.").Error())
+		return
+	}
+	authData, err := utils.CurrentWebAuthnConfig().VerifyRegistration(clientDataJSON, attestationObject, challenge.Challenge)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Passkey registration could not be verified.").Error())
+		return
+	}
+
+	if credential.Name == "" {
+		credential.Name = "Passkey"
+	}
+	passkey, err := passkeyRepo.CreatePasskey(models.Passkey{
+		ExecID:       execId,
+		Name:         credential.Name,
+		CredentialID: base64.RawURLEncoding.EncodeToString(authData.CredentialID),
+		PublicKey:    authData.PublicKey,
+		SignCount:    authData.SignCount,
+		AAGUID:       authData.AAGUIDString(),
+	})
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(passkey)
+}
+
+// GET /execs/me/passkeys
+func GetMyPasskeysHandler(w http.ResponseWriter, r *http.Request) {
+	passkeys, err := passkeyRepo.GetExecPasskeys(currentExecID(r))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	resp := struct {
+		Status string           `json:"status"`
+		Count  int              `json:"count"`
+		Data   []models.Passkey `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(passkeys),
+		Data:   passkeys,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DELETE /execs/me/passkeys/{id}
+func DeleteMyPasskeyHandler(w http.ResponseWriter, r *http.Request) {
+	passkeyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Passkey ID.").Error())
+		return
+	}
+
+	// Passkeys of other execs are reported as not found.
+	err = passkeyRepo.DeletePasskey(currentExecID(r), passkeyId)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /execs/login/passkey/begin
+func BeginPasskeyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	config := utils.CurrentWebAuthnConfig()
+	challenge, err := beginPasskeyCeremony(0, models.PasskeyLogin, config.Timeout)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	options := passkeyRequestOptions{
+		Challenge:        challenge,
+		RPID:             config.RPID,
+		Timeout:          config.Timeout.Milliseconds(),
+		UserVerification: "preferred",
+		AllowCredentials: make([]passkeyDescriptor, 0),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		PublicKey passkeyRequestOptions `json:"publicKey"`
+	}{options})
+}
+
+// POST /execs/login/passkey/finish
+func FinishPasskeyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var credential models.PasskeyCredential
+	err := json.NewDecoder(r.Body).Decode(&credential)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
+		return
+	}
+	defer r.Body.Close()
+
+	err = utils.ValidateStruct(credential)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// Failed assertions count towards the same per IP limit as failed passwords.
+	ip := clientIP(r)
+	now := time.Now()
+	wait := loginAttemptsByIP.retryAfter(ip, now)
+	if wait > 0 {
+		writeRetryAfter(w, r, http.StatusTooManyRequests, wait, "Too many failed logins from this address, try again later.")
+		return
+	}
+
+	clientDataJSON, challenge, err := consumePasskeyChallenge(credential, models.PasskeyLogin)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	rawId, err := utils.DecodeBase64URL(credential.RawID)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid rawId.").Error())
+		return
+	}
+	passkey, err := passkeyRepo.GetPasskeyByCredentialID(base64.RawURLEncoding.EncodeToString(rawId))
+	if errors.Is(err, utils.ErrUnauthorized) {
+		loginAttemptsByIP.fail(ip, now)
+		utils.WriteError(w, r, err)
+		return
+	} else if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	authData, err := verifyPasskeyAssertion(credential, clientDataJSON, challenge, passkey)
+	if err != nil {
+		loginAttemptsByIP.fail(ip, now)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Passkey could not be verified.").Error())
+		return
+	}
+	if !utils.SignCountValid(passkey.SignCount, authData.SignCount) {
+		log.Printf("Passkey %d of exec %d sent sign count %d after %d, it may have been cloned\n", passkey.ID, passkey.ExecID, authData.SignCount, passkey.SignCount)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, "Passkey sign counter did not increase, the authenticator may have been cloned.")
+		return
+	}
+	err = passkeyRepo.UsePasskey(passkey.ID, passkey.SignCount, authData.SignCount, now)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	// Same check as LoginExecDBHandler.
+	exec, err := execRepo.GetOneExec(passkey.ExecID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if exec.InactiveStatus {
+		utils.WriteProblem(w, r, http.StatusForbidden, utils.TypedErrorHandler(errors.New("account is inactive"), utils.ErrForbidden, "Account is inactive.").Error())
+		return
+	}
+
+	// A passkey that verified the user, e.g. with a PIN or a fingerprint, is already two factors.
+	completeLogin(w, r, exec, authData.UserVerified())
+}
+
+func verifyPasskeyAssertion(credential models.PasskeyCredential, clientDataJSON []byte, challenge models.PasskeyChallenge, passkey models.Passkey) (utils.AuthenticatorData, error) {
+	// The user handle is optional, when sent it has to be the owner of the passkey.
+	if credential.Response.UserHandle != "" {
+		userHandle, err := utils.DecodeBase64URL(credential.Response.UserHandle)
+		if err != nil || base64.RawURLEncoding.EncodeToString(userHandle) != passkeyUserHandle(passkey.ExecID) {
+			return utils.AuthenticatorData{}, errors.New("user handle doesn't match the passkey")
+		}
+	}
+	authenticatorData, err := utils.DecodeBase64URL(credential.Response.AuthenticatorData)
+	if err != nil {
+		return utils.AuthenticatorData{}, err
+	}
+	signature, err := utils.DecodeBase64URL(credential.Response.Signature)
+	if err != nil {
+		return utils.AuthenticatorData{}, err
+	}
+	return utils.CurrentWebAuthnConfig().VerifyAssertion(clientDataJSON, authenticatorData, signature, passkey.PublicKey, challenge.Challenge)
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import "github.com/brickster241/rest-go/internal/repository"
+
+// Repositories used by the handlers, injected at startup via SetRepositories.
+var (
+	teacherRepo      repository.TeacherRepository
+	studentRepo      repository.StudentRepository
+	execRepo         repository.ExecRepository
+	searchRepo       repository.SearchRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	revokedTokenRepo repository.RevokedTokenRepository
+	mfaRepo          repository.MFARepository
+	apiKeyRepo       repository.APIKeyRepository
+	sessionRepo      repository.SessionRepository
+	passkeyRepo      repository.PasskeyRepository
+)
+
+func SetRepositories(repos repository.Repositories) {
+	teacherRepo = repos.Teachers
+	studentRepo = repos.Students
+	execRepo = repos.Execs
+	searchRepo = repos.Search
+	refreshTokenRepo = repos.RefreshTokens
+	revokedTokenRepo = repos.RevokedTokens
+	mfaRepo = repos.MFA
+	apiKeyRepo = repos.APIKeys
+	sessionRepo = repos.Sessions
+	passkeyRepo = repos.Passkeys
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// GET /search?q=
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	// Every term matches as a prefix, so "jo sm" finds "John Smith".
+	terms := utils.SearchTerms(r.URL.Query().Get("q"))
+	if len(terms) == 0 {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "Query parameter q is required.")
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(r)
+	results, total, err := searchRepo.Search(terms, page, limit)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	for i := range results {
+		results[i].Link = fmt.Sprintf("/%ss/%d", results[i].Type, results[i].ID)
+	}
+
+	resp := struct {
+		Status   string `json:"status"`
+		Count    int    `json:"count"`
+		Page     int    `json:"page"`
+		PageSize int    `json:"page_size"`
+		utils.PageInfo
+		Data []models.SearchResult `json:"data"`
+	}{
+		Status:   "success",
+		Count:    total,
+		Page:     page,
+		PageSize: limit,
+		PageInfo: utils.GetPageInfo(r, page, limit, total, ""),
+		Data:     results,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Longest user agent kept on a session, the column is VARCHAR(512).
+const maxUserAgentLength = 512
+
+// GET /execs/me/sessions
+func GetMySessionsHandler(w http.ResponseWriter, r *http.Request) {
+	sessions, err := sessionRepo.GetExecSessions(currentExecID(r))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID(r)
+	}
+
+	resp := struct {
+		Status string           `json:"status"`
+		Count  int              `json:"count"`
+		Data   []models.Session `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(sessions),
+		Data:   sessions,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DELETE /execs/me/sessions/{id}
+func DeleteMySessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Session ID.").Error())
+		return
+	}
+
+	// Sessions of other execs are reported as not found.
+	err = sessionRepo.DeleteSession(currentExecID(r), sessionId)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /execs/{id}/sessions
+func DeleteExecSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	execId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Exec ID.").Error())
+		return
+	}
+
+	count, err := sessionRepo.DeleteExecSessions(execId)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+		Count  int    `json:"count"`
+	}{
+		Status: "Exec sessions successfully terminated.",
+		ID:     execId,
+		Count:  count,
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Session of the login token, placed in the context by JWT_MW.
+func currentSessionID(r *http.Request) int {
+	sessionId, _ := r.Context().Value(utils.ContextKey("sessionId")).(float64)
+	return int(sessionId)
+}
+
+// Records a new login of execId from the client of r, valid until expiresAt.
+func startSession(r *http.Request, execId int, expiresAt time.Time) (models.Session, error) {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	return sessionRepo.CreateSession(models.Session{
+		ExecID:    execId,
+		UserAgent: userAgent,
+		IP:        clientIP(r),
+		ExpiresAt: expiresAt,
+	})
+}
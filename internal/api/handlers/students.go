@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 	"strconv"
@@ -10,7 +9,7 @@ import (
 	"sync"
 
 	models "github.com/brickster241/rest-go/internal/models"
-	"github.com/brickster241/rest-go/internal/repository/sqlconnect"
+	"github.com/brickster241/rest-go/internal/repository"
 	"github.com/brickster241/rest-go/pkg/utils"
 )
 
@@ -18,43 +17,58 @@ var mu_sdnt = &sync.Mutex{}
 
 // GET students/
 func GetStudentsHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "manager", "exec")
+	page, limit := utils.GetPaginationParams(r)
+	// Filter based on different params, sorting will be of type param:asc or param:desc
+	filters, err := addQueryFiltersStudent(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	sortFields := applySortingFiltersStudent(r)
+	opts := repository.ListOptions{
+		Filters: filters,
+		Sort:    sortFields,
+		Page:    page,
+		Limit:   limit,
+		Search:  utils.SearchTerms(r.URL.Query().Get("q")),
+	}
 
-	query := "SELECT id, first_name, last_name, email, class FROM students WHERE 1=1"
-	var args []interface{}
-	
-	// Filter based on different params
-	query, args = addQueryFiltersStudent(r, query, args)
-
-	// Will be of type param:asc or param:desc
-	query = applySortingFiltersStudent(r, query)
-
-	page, limit := utils.GetPaginationParams(r)
-	offset := (page - 1) * limit
-	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	// ?cursor= lists the rows after the cursor, one extra row tells if there is a next page.
+	cursor, cursorMode, err := getCursor(r, sortFields)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "Invalid cursor.")
+		return
+	}
+	if cursorMode {
+		page = 0
+		opts.Page, opts.Limit, opts.After = 1, limit+1, cursor
+	}
 	
 	// Connect to DB
-	studentList, totalStudents, err := sqlconnect.GetStudentsDBHandler(query, args)
+	studentList, totalStudents, err := studentRepo.GetStudents(opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
+	studentList, nextCursor := keysetPage(studentList, limit, !cursorMode && page*limit < totalStudents, sortFields)
+	pageInfo := utils.GetPageInfo(r, page, limit, totalStudents, nextCursor)
+	if cursorMode {
+		pageInfo = utils.GetCursorPageInfo(r, limit, cursor != nil, totalStudents, nextCursor)
+	}
 
 	resp := struct {
 		Status string    `json:"status"`
 		Count  int       `json:"count"`
-		Page int		`json:"page"`
+		Page int		`json:"page,omitempty"`
 		PageSize int 	`json:"page_size"`
+		utils.PageInfo
 		Data   []models.Student `json:"data"`
 	}{
 		Status: "success",
 		Count:  totalStudents,
 		Page: page,
 		PageSize: limit,
+		PageInfo: pageInfo,
 		Data:   studentList,
 	}
 
@@ -64,24 +78,18 @@ func GetStudentsHandler(w http.ResponseWriter, r *http.Request) {
 
 // GET /students/{id}
 func GetOneStudentHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "manager", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	idStr := r.PathValue("id")
 	// Handle Path Parameters
 	studentId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Student ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Student ID.").Error())
 		return
 	}
 
 	// Connect to DB
-	sdnt, err := sqlconnect.GetOneStudentDBHandler(studentId)
+	sdnt, err := studentRepo.GetOneStudent(studentId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -89,31 +97,22 @@ func GetOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(sdnt)
 }
 
-func applySortingFiltersStudent(r *http.Request, query string) string {
+func applySortingFiltersStudent(r *http.Request) []repository.SortField {
 	sortParams := r.URL.Query()["sortby"]
-	addQuery := " ORDER BY"
-	if len(sortParams) > 0 {
-		for i, sortParam := range sortParams {
-			parts := strings.Split(sortParam, ":")
-			if len(parts) != 2 {
-				continue
-			}
-
-			field, order := parts[0], parts[1]
-			if !isValidOrder(order) || !isValidSortFieldStudent(field) {
-				continue
-			}
-			// To ensure to incorporate multiple sorting values
-			if i > 0 {
-				addQuery += ","
-			}
-			addQuery += fmt.Sprintf(" %s %s", field, order)
+	var sortFields []repository.SortField
+	for _, sortParam := range sortParams {
+		parts := strings.Split(sortParam, ":")
+		if len(parts) != 2 {
+			continue
 		}
-		if addQuery != " ORDER BY" {
-			query += addQuery
+
+		field, order := parts[0], parts[1]
+		if !isValidOrder(order) || !isValidSortFieldStudent(field) {
+			continue
 		}
+		sortFields = append(sortFields, repository.SortField{Field: field, Order: order})
 	}
-	return query
+	return sortFields
 }
 
 func isValidSortFieldStudent(field string) bool {
@@ -126,32 +125,22 @@ func isValidSortFieldStudent(field string) bool {
 	return validFields[field]
 }
 
-func addQueryFiltersStudent(r *http.Request, query string, args []interface{}) (string, []interface{}) {
-	params := []string{
-		"first_name",
-		"last_name",
-		"email",
-		"class",
-	}
+// Filterable fields and their types, see parseQueryFilters.
+var studentFilterFields = map[string]string{
+	"id":         filterInt,
+	"first_name": filterString,
+	"last_name":  filterString,
+	"email":      filterString,
+	"class":      filterString,
+	"created_at": filterTime,
+}
 
-	for _, param := range params {
-		value := r.URL.Query().Get(param)
-		if value != "" {
-			query += fmt.Sprintf(" AND %s=$%d", param, len(args)+1)
-			args = append(args, value)
-		}
-	}
-	return query, args
+func addQueryFiltersStudent(r *http.Request) ([]repository.Filter, error) {
+	return parseQueryFilters(r, studentFilterFields)
 }
 
 // POST /students/
 func PostStudentsHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_sdnt.Lock()
 	defer mu_sdnt.Unlock()
@@ -161,14 +150,14 @@ func PostStudentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Error reading Request Body.", http.StatusInternalServerError)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, "Error reading Request Body.")
 		return
 	}
 	defer r.Body.Close()
 
 	err = json.Unmarshal(body, &rawStudents)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Request Body.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
 		return
 	}
 
@@ -182,7 +171,7 @@ func PostStudentsHandler(w http.ResponseWriter, r *http.Request) {
 		for key := range student {
 			_, ok := allowedFields[key]
 			if !ok {
-				http.Error(w, "Unacceptable Field found in request.", http.StatusBadRequest)
+				utils.WriteProblem(w, r, http.StatusBadRequest, "Unacceptable Field found in request.")
 				return
 			}
 		}
@@ -190,22 +179,21 @@ func PostStudentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = json.Unmarshal(body, &newStudents)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Request Body.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
 		return
 	}
 	
-	// Check whether all fields are non empty.
-	for _, student := range newStudents {
-		err := utils.CheckBlankFields(student)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		}
+	// Validate every record, so the client sees all the failing records and fields at once.
+	err = utils.ValidateItems(newStudents)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
 	}
 
 	// Connect to DB
-	addedStudents, err := sqlconnect.PostStudentsDBHandler(newStudents)
+	addedStudents, err := studentRepo.PostStudents(newStudents)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 	
@@ -226,12 +214,6 @@ func PostStudentsHandler(w http.ResponseWriter, r *http.Request) {
 
 // PUT /students/{id}
 func PutOneStudentHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_sdnt.Lock()
 	defer mu_sdnt.Unlock()
@@ -241,21 +223,27 @@ func PutOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 	// Handle Path Parameters
 	studentId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Student ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Student ID.").Error())
 		return
 	}
 
 	var updatedSdnt models.Student
 	err = json.NewDecoder(r.Body).Decode(&updatedSdnt)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Student Payload.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Student Payload.").Error())
+		return
+	}
+
+	err = utils.ValidateStruct(updatedSdnt)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// Connect to DB
-	err = sqlconnect.PutOneStudentDBHandler(studentId, updatedSdnt)
+	err = studentRepo.PutOneStudent(studentId, updatedSdnt)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -266,12 +254,6 @@ func PutOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 
 // PATCH /students/{id}
 func PatchOneStudentHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_sdnt.Lock()
 	defer mu_sdnt.Unlock()
@@ -281,7 +263,7 @@ func PatchOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 	// Handle Path Parameters
 	studentId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Student ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Student ID.").Error())
 		return
 	}
 
@@ -289,14 +271,20 @@ func PatchOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 	var updates map[string]interface{}
 	err = json.NewDecoder(r.Body).Decode(&updates)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Payload Request.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Payload Request.").Error())
+		return
+	}
+
+	err = utils.ValidateUpdates(models.Student{}, updates)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// Connect to DB
-	existingSdnt, err := sqlconnect.PatchOneStudentDBHandler(studentId, updates)
+	existingSdnt, err := studentRepo.PatchOneStudent(studentId, updates)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -308,27 +296,27 @@ func PatchOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 // PATCH /students/{id}
 func PatchStudentsHandler(w http.ResponseWriter, r *http.Request) {
 	
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_sdnt.Lock()
 	defer mu_sdnt.Unlock()
 
 	// Get specific patch keys
 	var updates []map[string]interface{}
-	err = json.NewDecoder(r.Body).Decode(&updates)
+	err := json.NewDecoder(r.Body).Decode(&updates)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Payload Request.").Error())
+		return
+	}
+
+	err = utils.ValidateBulkUpdates(models.Student{}, updates)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Payload Request.").Error(), http.StatusBadRequest)
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	existingSdnts, err := sqlconnect.PatchStudentsDBHandler(updates)
+	existingSdnts, err := studentRepo.PatchStudents(updates)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -341,12 +329,6 @@ func PatchStudentsHandler(w http.ResponseWriter, r *http.Request) {
 // DELETE /students/{id}
 func DeleteOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 	
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_sdnt.Lock()
 	defer mu_sdnt.Unlock()
@@ -356,14 +338,14 @@ func DeleteOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 	// Handle Path Parameters
 	studentId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid student ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid student ID.").Error())
 		return
 	}
 
 	// Connect to DB
-	err = sqlconnect.DeleteOneStudentDBHandler(studentId)
+	err = studentRepo.DeleteOneStudent(studentId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -382,28 +364,22 @@ func DeleteOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 // DELETE /students/
 func DeleteStudentsHandler(w http.ResponseWriter, r *http.Request) {
 	
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_sdnt.Lock()
 	defer mu_sdnt.Unlock()
 
 	// Get specific ids to delete
 	var ids []int
-	err = json.NewDecoder(r.Body).Decode(&ids)
+	err := json.NewDecoder(r.Body).Decode(&ids)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Payload Request.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Payload Request.").Error())
 		return
 	}
 
 	// Connect to DB
-	err = sqlconnect.DeleteStudentsDBHandler(ids)
+	err = studentRepo.DeleteStudents(ids)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 	
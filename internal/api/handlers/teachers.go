@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 	"strconv"
@@ -10,7 +9,7 @@ import (
 	"sync"
 
 	models "github.com/brickster241/rest-go/internal/models"
-	"github.com/brickster241/rest-go/internal/repository/sqlconnect"
+	"github.com/brickster241/rest-go/internal/repository"
 	"github.com/brickster241/rest-go/pkg/utils"
 )
 
@@ -18,43 +17,58 @@ var mu_tchr = &sync.Mutex{}
 
 // GET teachers/
 func GetTeachersHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
+	page, limit := utils.GetPaginationParams(r)
+	// Filter based on different params, sorting will be of type param:asc or param:desc
+	filters, err := addQueryFiltersTeacher(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	sortFields := applySortingFiltersTeacher(r)
+	opts := repository.ListOptions{
+		Filters: filters,
+		Sort:    sortFields,
+		Page:    page,
+		Limit:   limit,
+		Search:  utils.SearchTerms(r.URL.Query().Get("q")),
+	}
 
-	query := "SELECT id, first_name, last_name, email, class, subject FROM teachers WHERE 1=1"
-	var args []interface{}
-	
-	// Filter based on different params
-	query, args = addQueryFiltersTeacher(r, query, args)
-
-	// Will be of type param:asc or param:desc
-	query = applySortingFiltersTeacher(r, query)
-
-	page, limit := utils.GetPaginationParams(r)
-	offset := (page - 1) * limit
-	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	// ?cursor= lists the rows after the cursor, one extra row tells if there is a next page.
+	cursor, cursorMode, err := getCursor(r, sortFields)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, "Invalid cursor.")
+		return
+	}
+	if cursorMode {
+		page = 0
+		opts.Page, opts.Limit, opts.After = 1, limit+1, cursor
+	}
 
 	// Connect to DB
-	teacherList, totalTeachers, err := sqlconnect.GetTeachersDBHandler(query, args)
+	teacherList, totalTeachers, err := teacherRepo.GetTeachers(opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
+	teacherList, nextCursor := keysetPage(teacherList, limit, !cursorMode && page*limit < totalTeachers, sortFields)
+	pageInfo := utils.GetPageInfo(r, page, limit, totalTeachers, nextCursor)
+	if cursorMode {
+		pageInfo = utils.GetCursorPageInfo(r, limit, cursor != nil, totalTeachers, nextCursor)
+	}
 
 	resp := struct {
 		Status string    `json:"status"`
 		Count  int       `json:"count"`
-		Page int `json:"page"`
+		Page int `json:"page,omitempty"`
 		PageSize int `json:"page_size"`
+		utils.PageInfo
 		Data   []models.Teacher `json:"data"`
 	}{
 		Status: "success",
 		Count:  totalTeachers,
 		Page: page,
 		PageSize: limit,
+		PageInfo: pageInfo,
 		Data:   teacherList,
 	}
 
@@ -64,24 +78,18 @@ func GetTeachersHandler(w http.ResponseWriter, r *http.Request) {
 
 // GET /teachers/{id}
 func GetOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	idStr := r.PathValue("id")
 	// Handle Path Parameters
 	teacherId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Teacher ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Teacher ID.").Error())
 		return
 	}
 
 	// Connect to DB
-	tchr, err := sqlconnect.GetOneTeacherDBHandler(teacherId)
+	tchr, err := teacherRepo.GetOneTeacher(teacherId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -91,25 +99,19 @@ func GetOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 
 // GET /teachers/{id}/students
 func GetStudentsByTeacherIDHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	var students []models.Student
 	idStr := r.PathValue("id")
 	
 	// Handle Path Parameters
 	teacherId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Teacher ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Teacher ID.").Error())
 		return
 	}
 
-	students, err = sqlconnect.GetStudentsByTeachersIDDBHandler(teacherId, students)
+	students, err = teacherRepo.GetStudentsByTeacherID(teacherId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 	
@@ -132,24 +134,19 @@ func GetStudentsByTeacherIDHandler(w http.ResponseWriter, r *http.Request) {
 // GET /teachers/{id}/studentcount
 func GetStudentCountByTeacherIDHandler(w http.ResponseWriter, r *http.Request) {
 	
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 	
 	idStr := r.PathValue("id")
 	
 	// Handle Path Parameters
 	teacherId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Teacher ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Teacher ID.").Error())
 		return
 	}
 
-	studentCount, err := sqlconnect.GetStudentCountByTeacherIDDBHandler(teacherId)
+	studentCount, err := teacherRepo.GetStudentCountByTeacherID(teacherId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -167,31 +164,22 @@ func GetStudentCountByTeacherIDHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-func applySortingFiltersTeacher(r *http.Request, query string) string {
+func applySortingFiltersTeacher(r *http.Request) []repository.SortField {
 	sortParams := r.URL.Query()["sortby"]
-	addQuery := " ORDER BY"
-	if len(sortParams) > 0 {
-		for i, sortParam := range sortParams {
-			parts := strings.Split(sortParam, ":")
-			if len(parts) != 2 {
-				continue
-			}
-
-			field, order := parts[0], parts[1]
-			if !isValidOrder(order) || !isValidSortFieldTeacher(field) {
-				continue
-			}
-			// To ensure to incorporate multiple sorting values
-			if i > 0 {
-				addQuery += ","
-			}
-			addQuery += fmt.Sprintf(" %s %s", field, order)
+	var sortFields []repository.SortField
+	for _, sortParam := range sortParams {
+		parts := strings.Split(sortParam, ":")
+		if len(parts) != 2 {
+			continue
 		}
-		if addQuery != " ORDER BY" {
-			query += addQuery
+
+		field, order := parts[0], parts[1]
+		if !isValidOrder(order) || !isValidSortFieldTeacher(field) {
+			continue
 		}
+		sortFields = append(sortFields, repository.SortField{Field: field, Order: order})
 	}
-	return query
+	return sortFields
 }
 
 func isValidSortFieldTeacher(field string) bool {
@@ -209,33 +197,23 @@ func isValidOrder(order string) bool {
 	return order == "asc" || order == "desc"
 }
 
-func addQueryFiltersTeacher(r *http.Request, query string, args []interface{}) (string, []interface{}) {
-	params := []string{
-		"first_name",
-		"last_name",
-		"email",
-		"class",
-		"subject",
-	}
+// Filterable fields and their types, see parseQueryFilters.
+var teacherFilterFields = map[string]string{
+	"id":         filterInt,
+	"first_name": filterString,
+	"last_name":  filterString,
+	"email":      filterString,
+	"class":      filterString,
+	"subject":    filterString,
+	"created_at": filterTime,
+}
 
-	for _, param := range params {
-		value := r.URL.Query().Get(param)
-		if value != "" {
-			query += fmt.Sprintf(" AND %s=$%d", param, len(args)+1)
-			args = append(args, value)
-		}
-	}
-	return query, args
+func addQueryFiltersTeacher(r *http.Request) ([]repository.Filter, error) {
+	return parseQueryFilters(r, teacherFilterFields)
 }
 
 // POST /teachers/
 func PostTeachersHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_tchr.Lock()
 	defer mu_tchr.Unlock()
@@ -245,14 +223,14 @@ func PostTeachersHandler(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Error reading Request Body.", http.StatusInternalServerError)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, "Error reading Request Body.")
 		return
 	}
 	defer r.Body.Close()
 
 	err = json.Unmarshal(body, &rawTeachers)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Request Body.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
 		return
 	}
 
@@ -266,7 +244,7 @@ func PostTeachersHandler(w http.ResponseWriter, r *http.Request) {
 		for key := range teacher {
 			_, ok := allowedFields[key]
 			if !ok {
-				http.Error(w, "Unacceptable Field found in request.", http.StatusBadRequest)
+				utils.WriteProblem(w, r, http.StatusBadRequest, "Unacceptable Field found in request.")
 				return
 			}
 		}
@@ -274,22 +252,21 @@ func PostTeachersHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = json.Unmarshal(body, &newTeachers)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Request Body.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Request Body.").Error())
 		return
 	}
 	
-	// Check whether all fields are non empty.
-	for _, teacher := range newTeachers {
-		err := utils.CheckBlankFields(teacher)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		}
+	// Validate every record, so the client sees all the failing records and fields at once.
+	err = utils.ValidateItems(newTeachers)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
 	}
 
 	// Connect to DB
-	addedTeachers, err := sqlconnect.PostTeachersDBHandler(newTeachers)
+	addedTeachers, err := teacherRepo.PostTeachers(newTeachers)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 	
@@ -311,12 +288,6 @@ func PostTeachersHandler(w http.ResponseWriter, r *http.Request) {
 // PUT /teachers/{id}
 func PutOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 	
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_tchr.Lock()
 	defer mu_tchr.Unlock()
@@ -326,21 +297,27 @@ func PutOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 	// Handle Path Parameters
 	teacherId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Teacher ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Teacher ID.").Error())
 		return
 	}
 
 	var updatedTchr models.Teacher
 	err = json.NewDecoder(r.Body).Decode(&updatedTchr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Teacher Payload.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Teacher Payload.").Error())
+		return
+	}
+
+	err = utils.ValidateStruct(updatedTchr)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// Connect to DB
-	err = sqlconnect.PutOneTeacherDBHandler(teacherId, updatedTchr)
+	err = teacherRepo.PutOneTeacher(teacherId, updatedTchr)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -352,12 +329,6 @@ func PutOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 // PATCH /teachers/{id}
 func PatchOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 	
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_tchr.Lock()
 	defer mu_tchr.Unlock()
@@ -367,7 +338,7 @@ func PatchOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 	// Handle Path Parameters
 	teacherId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Teacher ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Teacher ID.").Error())
 		return
 	}
 
@@ -375,14 +346,20 @@ func PatchOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 	var updates map[string]interface{}
 	err = json.NewDecoder(r.Body).Decode(&updates)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Payload Request.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Payload Request.").Error())
+		return
+	}
+
+	err = utils.ValidateUpdates(models.Teacher{}, updates)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// Connect to DB
-	existingTchr, err := sqlconnect.PatchOneTeacherDBHandler(teacherId, updates)
+	existingTchr, err := teacherRepo.PatchOneTeacher(teacherId, updates)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -394,27 +371,27 @@ func PatchOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 // PATCH /teachers/{id}
 func PatchTeachersHandler(w http.ResponseWriter, r *http.Request) {
 	
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin", "exec")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_tchr.Lock()
 	defer mu_tchr.Unlock()
 
 	// Get specific patch keys
 	var updates []map[string]interface{}
-	err = json.NewDecoder(r.Body).Decode(&updates)
+	err := json.NewDecoder(r.Body).Decode(&updates)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Payload Request.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Payload Request.").Error())
 		return
 	}
 
-	existingTchrs, err := sqlconnect.PatchTeachersDBHandler(updates)
+	err = utils.ValidateBulkUpdates(models.Teacher{}, updates)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	existingTchrs, err := teacherRepo.PatchTeachers(updates)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -427,12 +404,6 @@ func PatchTeachersHandler(w http.ResponseWriter, r *http.Request) {
 // DELETE /teachers/{id}
 func DeleteOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 	
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// Mutex variables
 	mu_tchr.Lock()
 	defer mu_tchr.Unlock()
@@ -442,14 +413,14 @@ func DeleteOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 	// Handle Path Parameters
 	teacherId, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid teacher ID.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid teacher ID.").Error())
 		return
 	}
 
 	// Connect to DB
-	err = sqlconnect.DeleteOneTeacherDBHandler(teacherId)
+	err = teacherRepo.DeleteOneTeacher(teacherId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -468,27 +439,22 @@ func DeleteOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 // DELETE /teachers/
 func DeleteTeachersHandler(w http.ResponseWriter, r *http.Request) {
 	
-	_, err := utils.AuthorizeUser(r.Context().Value(utils.ContextKey("role")).(string), "admin")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 	// Mutex variables
 	mu_tchr.Lock()
 	defer mu_tchr.Unlock()
 
 	// Get specific ids to delete
 	var ids []int
-	err = json.NewDecoder(r.Body).Decode(&ids)
+	err := json.NewDecoder(r.Body).Decode(&ids)
 	if err != nil {
-		http.Error(w, utils.ErrorHandler(err, "Invalid Payload Request.").Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrorHandler(err, "Invalid Payload Request.").Error())
 		return
 	}
 
 	// Connect to DB
-	err = sqlconnect.DeleteTeachersDBHandler(ids)
+	err = teacherRepo.DeleteTeachers(ids)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.WriteError(w, r, err)
 		return
 	}
 	
@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Header other services send their API key in.
+const APIKeyHeader = "X-API-Key"
+
+// last_used_at is only written once per interval, so busy services don't write on every request.
+const apiKeyTouchInterval = time.Minute
+
+// Looks up the API key and places its role and scopes in the context, in place of the exec of
+// a login token. There is no userId, so the /execs/me routes don't apply to keys.
+func (ja *jwtAuth) apiKeyContext(ctx context.Context, apiKey string) (context.Context, error) {
+	keyHash, err := utils.HashAPIKey(apiKey)
+	if err != nil {
+		return nil, &utils.AppError{Kind: utils.ErrUnauthorized, Msg: "Invalid API key."}
+	}
+	key, err := ja.apiKeys.GetAPIKeyByHash(keyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt, err := time.Parse(time.RFC3339Nano, key.ExpiresAt.String)
+	if err != nil || !now.Before(expiresAt) {
+		return nil, &utils.AppError{Kind: utils.ErrUnauthorized, Msg: "API key has expired."}
+	}
+
+	lastUsedAt, err := time.Parse(time.RFC3339Nano, key.LastUsedAt.String)
+	if err != nil || now.Sub(lastUsedAt) >= apiKeyTouchInterval {
+		// Only bookkeeping, a failure here doesn't fail the request.
+		err = ja.apiKeys.TouchAPIKey(key.ID, now)
+		if err != nil && !errors.Is(err, utils.ErrNotFound) {
+			log.Println("Couldn't record API key use :", err)
+		}
+	}
+
+	scopes := make([]utils.Permission, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = utils.Permission(scope)
+	}
+	ctx = context.WithValue(ctx, utils.ContextKey("role"), key.Role)
+	ctx = context.WithValue(ctx, utils.ContextKey("username"), "apikey:"+key.Name)
+	ctx = context.WithValue(ctx, utils.ContextKey("apiKeyId"), key.ID)
+	ctx = context.WithValue(ctx, utils.ContextKey("scopes"), scopes)
+	return ctx, nil
+}
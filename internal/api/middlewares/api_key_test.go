@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository/memory"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Service clients send no Origin, their API key has to get past CorsMW to JWT_MW.
+func TestAPIKeyWithoutOriginReachesHandler(t *testing.T) {
+	repos := memory.NewRepositories()
+	key, prefix, keyHash, err := utils.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repos.APIKeys.CreateAPIKey(models.APIKey{
+		Name:      "reports",
+		Role:      "service",
+		Scopes:    []string{string(utils.PermStudentsRead)},
+		Prefix:    prefix,
+		KeyHash:   keyHash,
+		ExpiresAt: sql.NullString{String: time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var role string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ = r.Context().Value(utils.ContextKey("role")).(string)
+	})
+	ja := NewJWTAuth(repos.Execs, repos.RevokedTokens, repos.APIKeys, repos.Sessions, JWTOptions{})
+	h := CorsMW(ja.JWT_MW(handler))
+
+	req := httptest.NewRequest(http.MethodGet, "/students", nil)
+	req.Header.Set(APIKeyHeader, key)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if role != "service" {
+		t.Errorf("role = %q, want the role of the API key", role)
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("CORS headers set on a request without Origin")
+	}
+}
+
+func TestCorsRejectsUnknownOrigin(t *testing.T) {
+	called := false
+	h := CorsMW(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/students", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden || called {
+		t.Errorf("status = %d, handler called = %v, want 403 without calling the handler", rec.Code, called)
+	}
+}
@@ -3,6 +3,8 @@ package middlewares
 import (
 	"log"
 	"net/http"
+
+	"github.com/brickster241/rest-go/pkg/utils"
 )
 
 var allowedOrigins = []string {
@@ -16,16 +18,24 @@ func CorsMW(next http.Handler) http.Handler {
 		log.Println("+++++++ CorsMW Ran +++++++")
 		origin := r.Header.Get("Origin")
 
+		// Requests without an Origin don't come from a page script: CLI, mobile and
+		// server to server clients, or a browser following a link. CORS doesn't apply to them.
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			log.Println("------- Sending Response from CorsMW -------")
+			return
+		}
+
 		// Only allow requests from specified urls' header.
 		if !isOriginAllowed(origin) {
-			http.Error(w, "Not Allowed by CORS.", http.StatusForbidden)
+			utils.WriteProblem(w, r, http.StatusForbidden, "Not Allowed by CORS.")
 			return
 		}
 		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Expose-Headers", "Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "Authorization, WWW-Authenticate, X-Request-ID")
 		w.Header().Set("Access-Control-Max-Age", "3600")
 
 		// Handle PreFlight check
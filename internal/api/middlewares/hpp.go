@@ -60,7 +60,9 @@ func filterBodyParams(r *http.Request, whitelist []string) {
 	}
 }
 
+// Filters like first_name[like] are whitelisted by their base name.
 func isWhiteListed(param string, whitelist []string) bool {
+	param, _, _ = strings.Cut(param, "[")
 	for _, v := range whitelist {
 		if param == v {
 			return true
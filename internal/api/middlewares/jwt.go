@@ -2,59 +2,213 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"strings"
+	"time"
 
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository"
 	"github.com/brickster241/rest-go/pkg/utils"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func JWT_MW(next http.Handler) http.Handler {
+// Places a login token can be read from, listed in JWTOptions.TokenSources.
+const (
+	TokenFromHeader = "header"
+	TokenFromCookie = "cookie"
+)
+
+// TokenSources is the precedence, the first source carrying a token wins. Defaults to the
+// Authorization header, then the "Bearer" cookie.
+type JWTOptions struct {
+	TokenSources []string
+}
+
+// Checks the login token on every request. Besides the signature and exp, a token is rejected
+// when its jti was revoked on logout, when it was issued before the last password change, or
+// when the account is inactive or its session was signed out. Other services send an
+// X-API-Key header instead.
+type jwtAuth struct {
+	execs         repository.ExecRepository
+	revokedTokens repository.RevokedTokenRepository
+	apiKeys       repository.APIKeyRepository
+	sessions      repository.SessionRepository
+	options       JWTOptions
+}
+
+func NewJWTAuth(execs repository.ExecRepository, revokedTokens repository.RevokedTokenRepository, apiKeys repository.APIKeyRepository, sessions repository.SessionRepository, options JWTOptions) *jwtAuth {
+	if len(options.TokenSources) == 0 {
+		options.TokenSources = []string{TokenFromHeader, TokenFromCookie}
+	}
+	return &jwtAuth{
+		execs:         execs,
+		revokedTokens: revokedTokens,
+		apiKeys:       apiKeys,
+		sessions:      sessions,
+		options:       options,
+	}
+}
+
+func (ja *jwtAuth) JWT_MW(next http.Handler) http.Handler {
 	log.Println("******* Initializing JWT_MW *******")
 	
 	return http.HandlerFunc(func (w http.ResponseWriter, r* http.Request)  {
 		log.Println("+++++++ JWT_MW Ran +++++++")
-		// Fetch the cookies and check
-		token, err := r.Cookie("Bearer")
-		if err != nil {
-			// Cookie not found.
-			http.Error(w, "Authorization Header Missing.", http.StatusForbidden)
+		// An API key takes the place of the login token.
+		apiKey := r.Header.Get(APIKeyHeader)
+		if apiKey != "" {
+			ctx, err := ja.apiKeyContext(r.Context(), apiKey)
+			if err != nil {
+				utils.WriteError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+			log.Println("------- Sending Response from JWT_MW -------")
 			return
 		}
 
-		jwtSecret := os.Getenv("JWT_SECRET")
-		parsedToken, err := jwt.Parse(token.Value, func (token *jwt.Token) (interface {}, error) {
-			
-			// Don't forget to validate the algo is what you expect.
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				utils.ErrorHandler(fmt.Errorf("unexpected signing method : %v", token.Header["alg"]), "Unauthorized.") 
-				return nil, err
-			}
-			return []byte(jwtSecret), nil
-		})
+		// Fetch the token from the header or cookie and check
+		token, ok := ja.getToken(r)
+		if !ok {
+			writeUnauthorized(w, r, "", "Login Token Missing, send an Authorization: Bearer header or the Bearer cookie.")
+			return
+		}
+
+		parsedToken, err := utils.ParseToken(token, utils.AccessTokenAudience)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			writeUnauthorized(w, r, "invalid_token", err.Error())
 			return
 			 
 		}
 		if !parsedToken.Valid {
-			http.Error(w, "Invalid Login Token", http.StatusUnauthorized)
+			writeUnauthorized(w, r, "invalid_token", "Invalid Login Token")
 			return
 		}
 		claims, ok := parsedToken.Claims.(jwt.MapClaims)
 		if !ok {
-			http.Error(w, "Invalid Login Token", http.StatusUnauthorized)
+			writeUnauthorized(w, r, "invalid_token", "Invalid Login Token")
+			return
+		}
+
+		exec, err := ja.checkTokenState(claims)
+		if errors.Is(err, utils.ErrUnauthorized) {
+			writeUnauthorized(w, r, "invalid_token", err.Error())
+			return
+		} else if err != nil {
+			utils.WriteError(w, r, err)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), utils.ContextKey("role"), claims["role"])
+		// The stored role wins over the claim, so a role change applies to live tokens.
+		ctx := context.WithValue(r.Context(), utils.ContextKey("role"), exec.Role)
 		ctx = context.WithValue(ctx, utils.ContextKey("expiresAt"), claims["exp"])
 		ctx = context.WithValue(ctx, utils.ContextKey("username"), claims["user"])
 		ctx = context.WithValue(ctx, utils.ContextKey("userId"), claims["uid"])
+		ctx = context.WithValue(ctx, utils.ContextKey("jti"), claims["jti"])
+		ctx = context.WithValue(ctx, utils.ContextKey("sessionId"), claims["sid"])
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 		log.Println("------- Sending Response from JWT_MW -------")
 	})
-}
\ No newline at end of file
+}
+
+// Returns the token of the first source in TokenSources that carries one.
+func (ja *jwtAuth) getToken(r *http.Request) (string, bool) {
+	for _, source := range ja.options.TokenSources {
+		switch source {
+		case TokenFromHeader:
+			scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+			token = strings.TrimSpace(token)
+			if found && strings.EqualFold(scheme, "Bearer") && token != "" {
+				return token, true
+			}
+		case TokenFromCookie:
+			cookie, err := r.Cookie("Bearer")
+			if err == nil && cookie.Value != "" {
+				return cookie.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// 401 with the WWW-Authenticate challenge of RFC 6750. errorCode is left out when no token was sent.
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, errorCode, detail string) {
+	challenge := `Bearer realm="rest-go"`
+	if errorCode != "" {
+		challenge += fmt.Sprintf(`, error="%s", error_description="%s"`, errorCode, strings.ReplaceAll(detail, `"`, "'"))
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	utils.WriteProblem(w, r, http.StatusUnauthorized, detail)
+}
+
+// Server side state of the token, so logout, password changes and deactivation apply at once.
+// Returns the stored auth state of the exec, whose role is used over the claim.
+func (ja *jwtAuth) checkTokenState(claims jwt.MapClaims) (models.Exec, error) {
+	jti, _ := claims["jti"].(string)
+	userId, _ := claims["uid"].(float64)
+	sessionId, _ := claims["sid"].(float64)
+	issuedAt, err := claims.GetIssuedAt()
+	// Tokens with a purpose, like the "mfa pending" one, are not login tokens.
+	_, hasPurpose := claims["purpose"]
+	if jti == "" || userId == 0 || sessionId == 0 || err != nil || issuedAt == nil || hasPurpose {
+		return models.Exec{}, &utils.AppError{Kind: utils.ErrUnauthorized, Msg: "Invalid Login Token"}
+	}
+
+	revoked, err := ja.revokedTokens.IsTokenRevoked(jti)
+	if err != nil {
+		return models.Exec{}, err
+	}
+	if revoked {
+		return models.Exec{}, &utils.AppError{Kind: utils.ErrUnauthorized, Msg: "Login Token has been revoked, please log in again."}
+	}
+	err = ja.checkSession(int(sessionId), int(userId))
+	if err != nil {
+		return models.Exec{}, err
+	}
+
+	exec, err := ja.execs.GetExecAuthState(int(userId))
+	if errors.Is(err, utils.ErrNotFound) {
+		return models.Exec{}, &utils.AppError{Kind: utils.ErrUnauthorized, Msg: "User no longer exists."}
+	} else if err != nil {
+		return models.Exec{}, err
+	}
+	if exec.InactiveStatus {
+		return models.Exec{}, &utils.AppError{Kind: utils.ErrUnauthorized, Msg: "Account is inactive."}
+	}
+
+	// iat only has second precision, so the change is compared in whole seconds too.
+	if exec.PasswordChangedAt.Valid {
+		changedAt, err := time.Parse(time.RFC3339Nano, exec.PasswordChangedAt.String)
+		if err == nil && issuedAt.Time.Before(changedAt.Truncate(time.Second)) {
+			return models.Exec{}, &utils.AppError{Kind: utils.ErrUnauthorized, Msg: "Password has been changed, please log in again."}
+		}
+	}
+	return exec, nil
+}
+
+// last_seen_at is only written once per interval, so a busy client doesn't write on every request.
+const sessionTouchInterval = time.Minute
+
+// The session of the token must still exist, it is deleted when signed out remotely.
+func (ja *jwtAuth) checkSession(sessionId int, userId int) error {
+	session, err := ja.sessions.GetSession(sessionId)
+	if errors.Is(err, utils.ErrNotFound) || err == nil && session.ExecID != userId {
+		return &utils.AppError{Kind: utils.ErrUnauthorized, Msg: "Session has been signed out, please log in again."}
+	} else if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		// Only bookkeeping, a failure here doesn't fail the request.
+		err = ja.sessions.TouchSession(sessionId, now, time.Time{})
+		if err != nil {
+			log.Println("Couldn't record session activity :", err)
+		}
+	}
+	return nil
+}
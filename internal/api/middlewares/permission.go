@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Attached to a route at registration time. The role placed in the context by JWT_MW must be
+// granted permission (see utils.HasPermission), otherwise the request is denied with a 403.
+// Requests made with an API key also need permission among the scopes of the key.
+func RequirePermission(permission utils.Permission, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value(utils.ContextKey("role")).(string)
+		err := utils.AuthorizeUser(role, permission)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		scopes, ok := r.Context().Value(utils.ContextKey("scopes")).([]utils.Permission)
+		if ok && !slices.Contains(scopes, permission) {
+			utils.WriteProblem(w, r, http.StatusForbidden, fmt.Sprintf("API key is missing the %s scope.", permission))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/brickster241/rest-go/pkg/utils"
 )
 
 type rateLimiter struct {
@@ -46,7 +48,7 @@ func (rl *rateLimiter) RateLimiterMW(next http.Handler) http.Handler {
 		log.Printf("Visitor Count from %v is : %v\n", visIP, rl.visitors[visIP])
 		
 		if rl.visitors[visIP] > rl.limit {
-			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			utils.WriteProblem(w, r, http.StatusTooManyRequests, "Too Many Requests")
 			return
 		}
 
@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Client supplied ids are only reused when they look sane.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+func RequestIDMW(next http.Handler) http.Handler {
+	log.Println("******* Initializing RequestIDMW *******")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Println("+++++++ RequestIDMW Ran +++++++")
+
+		requestId := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(requestId) {
+			idBytes := make([]byte, 16)
+			rand.Read(idBytes)
+			requestId = hex.EncodeToString(idBytes)
+		}
+
+		w.Header().Set("X-Request-ID", requestId)
+		ctx := context.WithValue(r.Context(), utils.ContextKey("requestId"), requestId)
+		next.ServeHTTP(w, r.WithContext(ctx))
+		log.Println("------- Sending Response from RequestIDMW -------")
+	})
+}
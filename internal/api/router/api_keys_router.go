@@ -0,0 +1,20 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/brickster241/rest-go/internal/api/handlers"
+	mw "github.com/brickster241/rest-go/internal/api/middlewares"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+func apiKeysRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Handle API key Routes
+	mux.Handle("GET /apikeys", mw.RequirePermission(utils.PermAPIKeysAdmin, handlers.GetAPIKeysHandler))
+	mux.Handle("POST /apikeys", mw.RequirePermission(utils.PermAPIKeysAdmin, handlers.PostAPIKeyHandler))
+	mux.Handle("DELETE /apikeys/{id}", mw.RequirePermission(utils.PermAPIKeysAdmin, handlers.DeleteAPIKeyHandler))
+
+	return mux
+}
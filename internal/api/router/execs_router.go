@@ -4,24 +4,53 @@ import (
 	"net/http"
 
 	"github.com/brickster241/rest-go/internal/api/handlers"
+	mw "github.com/brickster241/rest-go/internal/api/middlewares"
+	"github.com/brickster241/rest-go/pkg/utils"
 )
 
 func execsRouter() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Handle Exec Routes
-	mux.HandleFunc("GET /execs/", handlers.GetExecsHandler)
-	mux.HandleFunc("POST /execs", handlers.PostExecsHandler)
-	mux.HandleFunc("PATCH /execs", handlers.PatchExecsHandler)
-	mux.HandleFunc("GET /execs/{id}", handlers.GetOneExecHandler)
-	mux.HandleFunc("PATCH /execs/{id}", handlers.PatchOneExecHandler)
-	mux.HandleFunc("DELETE /execs/{id}", handlers.DeleteOneExecHandler)
+	mux.Handle("GET /execs/", mw.RequirePermission(utils.PermExecsRead, handlers.GetExecsHandler))
+	mux.Handle("POST /execs", mw.RequirePermission(utils.PermExecsAdmin, handlers.PostExecsHandler))
+	mux.Handle("PATCH /execs", mw.RequirePermission(utils.PermExecsWrite, handlers.PatchExecsHandler))
+	mux.Handle("GET /execs/me", mw.RequirePermission(utils.PermExecsSelf, handlers.GetOneExecHandler))
+	mux.Handle("PATCH /execs/me", mw.RequirePermission(utils.PermExecsSelf, handlers.PatchOneExecHandler))
+	mux.Handle("POST /execs/me/updatepassword", mw.RequirePermission(utils.PermExecsPassword, handlers.UpdateExecPasswordHandler))
+	mux.Handle("GET /execs/me/mfa", mw.RequirePermission(utils.PermExecsSelf, handlers.GetMFAStatusHandler))
+	mux.Handle("POST /execs/me/mfa/enroll", mw.RequirePermission(utils.PermExecsSelf, handlers.EnrollMFAHandler))
+	mux.Handle("POST /execs/me/mfa/verify", mw.RequirePermission(utils.PermExecsSelf, handlers.VerifyMFAHandler))
+	mux.Handle("POST /execs/me/mfa/disable", mw.RequirePermission(utils.PermExecsSelf, handlers.DisableMFAHandler))
+	mux.Handle("POST /execs/me/mfa/recoverycodes", mw.RequirePermission(utils.PermExecsSelf, handlers.RegenerateRecoveryCodesHandler))
+	mux.Handle("GET /execs/me/sessions", mw.RequirePermission(utils.PermExecsSelf, handlers.GetMySessionsHandler))
+	mux.Handle("DELETE /execs/me/sessions/{id}", mw.RequirePermission(utils.PermExecsSelf, handlers.DeleteMySessionHandler))
+	mux.Handle("GET /execs/me/passkeys", mw.RequirePermission(utils.PermExecsSelf, handlers.GetMyPasskeysHandler))
+	mux.Handle("POST /execs/me/passkeys/register/begin", mw.RequirePermission(utils.PermExecsSelf, handlers.BeginPasskeyRegistrationHandler))
+	mux.Handle("POST /execs/me/passkeys/register/finish", mw.RequirePermission(utils.PermExecsSelf, handlers.FinishPasskeyRegistrationHandler))
+	mux.Handle("DELETE /execs/me/passkeys/{id}", mw.RequirePermission(utils.PermExecsSelf, handlers.DeleteMyPasskeyHandler))
+	mux.Handle("GET /execs/{id}", mw.RequirePermission(utils.PermExecsRead, handlers.GetOneExecHandler))
+	mux.Handle("PATCH /execs/{id}", mw.RequirePermission(utils.PermExecsWrite, handlers.PatchOneExecHandler))
+	mux.Handle("DELETE /execs/{id}", mw.RequirePermission(utils.PermExecsAdmin, handlers.DeleteOneExecHandler))
+	mux.Handle("DELETE /execs/{id}/mfa", mw.RequirePermission(utils.PermExecsAdmin, handlers.ResetMFAHandler))
+	mux.Handle("POST /execs/{id}/unlock", mw.RequirePermission(utils.PermExecsAdmin, handlers.UnlockExecHandler))
+	mux.Handle("DELETE /execs/{id}/sessions", mw.RequirePermission(utils.PermExecsAdmin, handlers.DeleteExecSessionsHandler))
+	mux.Handle("POST /execs/{id}/invite", mw.RequirePermission(utils.PermExecsAdmin, handlers.ReissueExecInviteHandler))
 
-	mux.HandleFunc("POST /execs/{id}/updatepassword", handlers.UpdateExecPasswordHandler)
+	mux.Handle("POST /execs/{id}/updatepassword", mw.RequirePermission(utils.PermExecsPassword, handlers.UpdateExecPasswordHandler))
 	mux.HandleFunc("POST /execs/login", handlers.LoginExecHandler)
+	mux.HandleFunc("POST /execs/login/mfa", handlers.LoginMFAHandler)
+	mux.HandleFunc("POST /execs/login/mfa/enroll", handlers.EnrollMFAHandler)
+	mux.HandleFunc("POST /execs/login/magic", handlers.RequestMagicLinkHandler)
+	mux.HandleFunc("GET /execs/login/magic/{code}", handlers.MagicLinkPageHandler)
+	mux.HandleFunc("POST /execs/login/magic/{code}", handlers.MagicLinkLoginHandler)
+	mux.HandleFunc("POST /execs/login/passkey/begin", handlers.BeginPasskeyLoginHandler)
+	mux.HandleFunc("POST /execs/login/passkey/finish", handlers.FinishPasskeyLoginHandler)
+	mux.HandleFunc("POST /execs/refresh", handlers.RefreshExecTokenHandler)
 	mux.HandleFunc("POST /execs/logout", handlers.LogoutExecHandler)
 	mux.HandleFunc("POST /execs/forgotpassword", handlers.ForgotExecPasswordHandler)
 	mux.HandleFunc("POST /execs/resetpassword/reset/{resetcode}", handlers.ResetPasswordHandler)
+	mux.HandleFunc("POST /execs/invite/accept/{invitecode}", handlers.AcceptExecInviteHandler)
 
 	return mux
 }
\ No newline at end of file
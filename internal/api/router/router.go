@@ -9,8 +9,14 @@ func MainRouter() *http.ServeMux {
 	tRouter := teachersRouter()
 	sRouter := studentsRouter()
 	eRouter := execsRouter()
+	srRouter := searchRouter()
+	wkRouter := wellKnownRouter()
+	akRouter := apiKeysRouter()
 	
 	// Chaining Routers
+	wkRouter.Handle("/", akRouter)
+	srRouter.Handle("/", wkRouter)
+	eRouter.Handle("/", srRouter)
 	sRouter.Handle("/", eRouter)
 	tRouter.Handle("/", sRouter)
 	return tRouter
@@ -0,0 +1,19 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/brickster241/rest-go/internal/api/handlers"
+	mw "github.com/brickster241/rest-go/internal/api/middlewares"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+func searchRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+
+	// Handle search route
+	mux.Handle("GET /search", mw.RequirePermission(utils.PermSearchRead, handlers.SearchHandler))
+
+	return mux
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/brickster241/rest-go/internal/api/handlers"
+	mw "github.com/brickster241/rest-go/internal/api/middlewares"
+	"github.com/brickster241/rest-go/pkg/utils"
 )
 
 func studentsRouter() *http.ServeMux {
@@ -11,14 +13,14 @@ func studentsRouter() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Handle students route
-	mux.HandleFunc("GET /students", handlers.GetStudentsHandler)
-	mux.HandleFunc("POST /students", handlers.PostStudentsHandler)
-	mux.HandleFunc("PATCH /students", handlers.PatchStudentsHandler)
-	mux.HandleFunc("DELETE /students", handlers.DeleteStudentsHandler)
-	mux.HandleFunc("GET /students/{id}", handlers.GetOneStudentHandler)
-	mux.HandleFunc("PUT /students/{id}", handlers.PutOneStudentHandler)
-	mux.HandleFunc("PATCH /students/{id}", handlers.PatchOneStudentHandler)
-	mux.HandleFunc("DELETE /students/{id}", handlers.DeleteOneStudentHandler)
+	mux.Handle("GET /students", mw.RequirePermission(utils.PermStudentsRead, handlers.GetStudentsHandler))
+	mux.Handle("POST /students", mw.RequirePermission(utils.PermStudentsAdmin, handlers.PostStudentsHandler))
+	mux.Handle("PATCH /students", mw.RequirePermission(utils.PermStudentsWrite, handlers.PatchStudentsHandler))
+	mux.Handle("DELETE /students", mw.RequirePermission(utils.PermStudentsAdmin, handlers.DeleteStudentsHandler))
+	mux.Handle("GET /students/{id}", mw.RequirePermission(utils.PermStudentsRead, handlers.GetOneStudentHandler))
+	mux.Handle("PUT /students/{id}", mw.RequirePermission(utils.PermStudentsWrite, handlers.PutOneStudentHandler))
+	mux.Handle("PATCH /students/{id}", mw.RequirePermission(utils.PermStudentsWrite, handlers.PatchOneStudentHandler))
+	mux.Handle("DELETE /students/{id}", mw.RequirePermission(utils.PermStudentsAdmin, handlers.DeleteOneStudentHandler))
 
 	return mux
 }
\ No newline at end of file
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/brickster241/rest-go/internal/api/handlers"
+	mw "github.com/brickster241/rest-go/internal/api/middlewares"
+	"github.com/brickster241/rest-go/pkg/utils"
 )
 
 func teachersRouter() *http.ServeMux {
@@ -11,16 +13,16 @@ func teachersRouter() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Handle teachers route
-	mux.HandleFunc("GET /teachers", handlers.GetTeachersHandler)
-	mux.HandleFunc("POST /teachers", handlers.PostTeachersHandler)
-	mux.HandleFunc("PATCH /teachers", handlers.PatchTeachersHandler)
-	mux.HandleFunc("DELETE /teachers", handlers.DeleteTeachersHandler)
-	mux.HandleFunc("GET /teachers/{id}", handlers.GetOneTeacherHandler)
-	mux.HandleFunc("PUT /teachers/{id}", handlers.PutOneTeacherHandler)
-	mux.HandleFunc("PATCH /teachers/{id}", handlers.PatchOneTeacherHandler)
-	mux.HandleFunc("DELETE /teachers/{id}", handlers.DeleteOneTeacherHandler)
-	mux.HandleFunc("GET /teachers/{id}/students", handlers.GetStudentsByTeacherIDHandler)
-	mux.HandleFunc("GET /teachers/{id}/studentcount", handlers.GetStudentCountByTeacherIDHandler)
+	mux.Handle("GET /teachers", mw.RequirePermission(utils.PermTeachersRead, handlers.GetTeachersHandler))
+	mux.Handle("POST /teachers", mw.RequirePermission(utils.PermTeachersWrite, handlers.PostTeachersHandler))
+	mux.Handle("PATCH /teachers", mw.RequirePermission(utils.PermTeachersWrite, handlers.PatchTeachersHandler))
+	mux.Handle("DELETE /teachers", mw.RequirePermission(utils.PermTeachersAdmin, handlers.DeleteTeachersHandler))
+	mux.Handle("GET /teachers/{id}", mw.RequirePermission(utils.PermTeachersRead, handlers.GetOneTeacherHandler))
+	mux.Handle("PUT /teachers/{id}", mw.RequirePermission(utils.PermTeachersWrite, handlers.PutOneTeacherHandler))
+	mux.Handle("PATCH /teachers/{id}", mw.RequirePermission(utils.PermTeachersWrite, handlers.PatchOneTeacherHandler))
+	mux.Handle("DELETE /teachers/{id}", mw.RequirePermission(utils.PermTeachersAdmin, handlers.DeleteOneTeacherHandler))
+	mux.Handle("GET /teachers/{id}/students", mw.RequirePermission(utils.PermTeachersRead, handlers.GetStudentsByTeacherIDHandler))
+	mux.Handle("GET /teachers/{id}/studentcount", mw.RequirePermission(utils.PermTeachersRead, handlers.GetStudentCountByTeacherIDHandler))
 
 	return mux
 }
\ No newline at end of file
@@ -0,0 +1,17 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/brickster241/rest-go/internal/api/handlers"
+)
+
+func wellKnownRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+
+	// Handle well-known routes
+	mux.HandleFunc("GET /.well-known/jwks.json", handlers.JWKSHandler)
+
+	return mux
+}
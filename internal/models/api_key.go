@@ -0,0 +1,32 @@
+package models
+
+import "database/sql"
+
+// A key used by other services instead of a login. Only the hash of the key is stored, the key
+// itself is returned once on creation. Prefix is its first characters, to tell keys apart.
+type APIKey struct {
+	ID         int            `json:"id,omitempty" db:"id,omitempty"`
+	Name       string         `json:"name,omitempty" db:"name,omitempty"`
+	Role       string         `json:"role,omitempty" db:"role,omitempty"`
+	Scopes     []string       `json:"scopes,omitempty" db:"scopes,omitempty"`
+	Prefix     string         `json:"prefix,omitempty" db:"prefix,omitempty"`
+	KeyHash    string         `json:"-" db:"key_hash,omitempty"`
+	ExpiresAt  sql.NullString `json:"expires_at,omitempty" db:"expires_at,omitempty"`
+	LastUsedAt sql.NullString `json:"last_used_at,omitempty" db:"last_used_at,omitempty"`
+	CreatedAt  sql.NullString `json:"created_at,omitempty" db:"created_at,omitempty"`
+	CreatedBy  int            `json:"created_by,omitempty" db:"created_by,omitempty"`
+}
+
+// Body of POST /apikeys. Role defaults to service, ExpiresAt (RFC 3339) to API_KEY_EXPIRES from now.
+type APIKeyRequest struct {
+	Name      string   `json:"name" validate:"required,max=255"`
+	Role      string   `json:"role" validate:"oneof=service exec"`
+	Scopes    []string `json:"scopes" validate:"required"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+// The key is only ever sent in this response.
+type APIKeyCreatedResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
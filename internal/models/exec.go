@@ -4,17 +4,24 @@ import "database/sql"
 
 type Exec struct {
 	ID                	int `json:"id,omitempty" db:"id,omitempty"`
-	FirstName         	string `json:"first_name,omitempty" db:"first_name,omitempty"`
-	LastName          	string `json:"last_name,omitempty" db:"last_name,omitempty"`
-	Email             	string `json:"email,omitempty" db:"email,omitempty"`
-	Username          	string `json:"username,omitempty" db:"username,omitempty"`
-	Password          	string `json:"password,omitempty" db:"password,omitempty"`
+	FirstName         	string `json:"first_name,omitempty" db:"first_name,omitempty" validate:"required,max=255"`
+	LastName          	string `json:"last_name,omitempty" db:"last_name,omitempty" validate:"required,max=255"`
+	Email             	string `json:"email,omitempty" db:"email,omitempty" validate:"required,email,max=255"`
+	Username          	string `json:"username,omitempty" db:"username,omitempty" validate:"required,max=255"`
+	Password          	string `json:"password,omitempty" db:"password,omitempty" validate:"max=255"`
 	PasswordChangedAt 	sql.NullString `json:"password_changed_at,omitempty" db:"password_changed_at,omitempty"`
 	UserCreatedAt     	sql.NullString `json:"user_created_at,omitempty" db:"user_created_at,omitempty"`
 	PasswordResetToken 	sql.NullString `json:"password_reset_token,omitempty" db:"password_reset_token,omitempty"`
 	PasswordTokenExpires sql.NullString `json:"password_token_expires,omitempty" db:"password_token_expires,omitempty"`
 	InactiveStatus    	bool `json:"inactive_status,omitempty" db:"inactive_status,omitempty"`
-	Role              	string `json:"role,omitempty" db:"role,omitempty"`
+	Role              	string `json:"role,omitempty" db:"role,omitempty" validate:"required,oneof=admin manager exec"`
+	FailedLoginAttempts	int `json:"failed_login_attempts,omitempty" db:"failed_login_attempts,omitempty" validate:"readonly"`
+	LastFailedLoginAt 	sql.NullString `json:"-" db:"last_failed_login_at,omitempty"`
+	LockedUntil       	sql.NullString `json:"locked_until,omitempty" db:"locked_until,omitempty" validate:"readonly"`
+	InviteToken       	sql.NullString `json:"-" db:"invite_token,omitempty"`
+	InviteTokenExpires	sql.NullString `json:"invite_token_expires,omitempty" db:"invite_token_expires,omitempty" validate:"readonly"`
+	MagicLinkToken    	sql.NullString `json:"-" db:"magic_link_token,omitempty"`
+	MagicLinkExpires  	sql.NullString `json:"-" db:"magic_link_expires,omitempty"`
 }
 
 type UpdatePasswordRequest struct {
@@ -23,6 +30,7 @@ type UpdatePasswordRequest struct {
 }
 
 type UpdatePasswordResponse struct {
-	Token string `json:"token"`
+	Token string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 	PasswordUpdated bool `json:"password_updated"`
 }
\ No newline at end of file
@@ -0,0 +1,26 @@
+package models
+
+// TOTP state of an exec. Secret is set on enrollment and only used once Enabled,
+// i.e. after the first code was verified. LastStep is the last TOTP time step accepted.
+type ExecMFA struct {
+	ExecID            int    `json:"exec_id" db:"id"`
+	Secret            string `json:"-" db:"mfa_secret"`
+	Enabled           bool   `json:"enabled" db:"mfa_enabled"`
+	LastStep          int64  `json:"-" db:"mfa_last_step"`
+	RecoveryCodesLeft int    `json:"recovery_codes_left"`
+}
+
+// Body of the MFA endpoints. MFAToken is only sent on the login step, either Code or
+// RecoveryCode proves the second factor.
+type MFARequest struct {
+	MFAToken     string `json:"mfa_token,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// MFAToken replaces the one sent to /execs/login/mfa/enroll, which is used up.
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	MFAToken        string `json:"mfa_token,omitempty"`
+}
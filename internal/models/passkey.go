@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// A WebAuthn credential an exec logs in with instead of a password. CredentialID is the
+// base64url credential id the browser sends back, PublicKey its COSE_Key.
+type Passkey struct {
+	ID           int       `json:"id" db:"id"`
+	ExecID       int       `json:"exec_id" db:"exec_id"`
+	Name         string    `json:"name" db:"name"`
+	CredentialID string    `json:"credential_id" db:"credential_id"`
+	PublicKey    []byte    `json:"-" db:"public_key"`
+	SignCount    uint32    `json:"sign_count" db:"sign_count"`
+	AAGUID       string    `json:"aaguid" db:"aaguid"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	LastUsedAt   time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+// Ceremonies a PasskeyChallenge is issued for.
+const (
+	PasskeyRegistration = "registration"
+	PasskeyLogin        = "login"
+)
+
+// Challenge of a ceremony in progress. ExecID is 0 for logins, where the exec is only
+// known once the credential is.
+type PasskeyChallenge struct {
+	Challenge string    `db:"challenge"`
+	ExecID    int       `db:"exec_id"`
+	Ceremony  string    `db:"ceremony"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// Body of the finish endpoints, a PublicKeyCredential as serialized by its toJSON(), with
+// the binary fields base64url encoded. Name only applies to registrations.
+type PasskeyCredential struct {
+	ID       string `json:"id" validate:"required"`
+	RawID    string `json:"rawId" validate:"required"`
+	Type     string `json:"type" validate:"required"`
+	Name     string `json:"name" validate:"max=255"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject,omitempty"`
+		AuthenticatorData string `json:"authenticatorData,omitempty"`
+		Signature         string `json:"signature,omitempty"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
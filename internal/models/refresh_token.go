@@ -0,0 +1,18 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// A hashed refresh token. Rotation marks the token used and issues the next one in the same family.
+type RefreshToken struct {
+	ID        int          `json:"id,omitempty" db:"id,omitempty"`
+	ExecID    int          `json:"exec_id,omitempty" db:"exec_id,omitempty"`
+	TokenHash string       `json:"-" db:"token_hash,omitempty"`
+	FamilyID  string       `json:"family_id,omitempty" db:"family_id,omitempty"`
+	SessionID int          `json:"session_id,omitempty" db:"session_id,omitempty"`
+	ExpiresAt time.Time    `json:"expires_at" db:"expires_at"`
+	UsedAt    sql.NullTime `json:"-" db:"used_at"`
+	RevokedAt sql.NullTime `json:"-" db:"revoked_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// A teacher or student matching a search, Type is either "teacher" or "student".
+type SearchResult struct {
+	Type      string  `json:"type"`
+	ID        int     `json:"id"`
+	FirstName string  `json:"first_name"`
+	LastName  string  `json:"last_name"`
+	Email     string  `json:"email"`
+	Class     string  `json:"class"`
+	Subject   string  `json:"subject,omitempty"`
+	Rank      float64 `json:"rank"`
+	Link      string  `json:"link"`
+}
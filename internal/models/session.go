@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// A login of an exec, on one device. Access tokens carry its id in the sid claim and refresh
+// tokens in session_id, so deleting the session signs that device out.
+type Session struct {
+	ID         int       `json:"id" db:"id"`
+	ExecID     int       `json:"exec_id" db:"exec_id"`
+	UserAgent  string    `json:"user_agent" db:"user_agent"`
+	IP         string    `json:"ip" db:"ip"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at" db:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+	// Set on listings, true for the session of the request.
+	Current bool `json:"current"`
+}
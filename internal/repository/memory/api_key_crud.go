@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+type APIKeyRepository struct {
+	store *Store
+}
+
+func (repo APIKeyRepository) CreateAPIKey(key models.APIKey) (models.APIKey, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	// Mirrors the foreign key on created_by and the UNIQUE on key_hash.
+	if _, ok := repo.store.execs[key.CreatedBy]; key.CreatedBy != 0 && !ok {
+		return models.APIKey{}, conflictError("created_by", fmt.Sprint(key.CreatedBy), "Error creating API key.")
+	}
+	if isTaken(repo.store.apiKeys, "key_hash", key.KeyHash, 0) {
+		return models.APIKey{}, conflictError("key_hash", key.KeyHash, "Error creating API key.")
+	}
+	key.ID = repo.store.newID("api_keys")
+	key.Scopes = slices.Clone(key.Scopes)
+	key.CreatedAt = sql.NullString{String: nowTimestamp(), Valid: true}
+	repo.store.apiKeys[key.ID] = key
+	return key, nil
+}
+
+func (repo APIKeyRepository) GetAPIKeys() ([]models.APIKey, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(repo.store.apiKeys))
+	for _, id := range sortedIDs(repo.store.apiKeys) {
+		keys = append(keys, repo.store.apiKeys[id])
+	}
+	return keys, nil
+}
+
+func (repo APIKeyRepository) GetAPIKeyByHash(keyHash string) (models.APIKey, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	for _, key := range repo.store.apiKeys {
+		if key.KeyHash == keyHash {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, utils.TypedErrorHandler(errors.New("api key not found"), utils.ErrUnauthorized, "Invalid API key.")
+}
+
+func (repo APIKeyRepository) TouchAPIKey(keyId int, usedAt time.Time) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	key, ok := repo.store.apiKeys[keyId]
+	if !ok {
+		return nil
+	}
+	key.LastUsedAt = sql.NullString{String: usedAt.UTC().Format(time.RFC3339Nano), Valid: true}
+	repo.store.apiKeys[keyId] = key
+	return nil
+}
+
+func (repo APIKeyRepository) DeleteAPIKey(keyId int) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	if _, ok := repo.store.apiKeys[keyId]; !ok {
+		return utils.TypedErrorHandler(errors.New("api key not found"), utils.ErrNotFound, fmt.Sprintf("API key %d not found.", keyId))
+	}
+	delete(repo.store.apiKeys, keyId)
+	return nil
+}
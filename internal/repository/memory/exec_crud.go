@@ -0,0 +1,545 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+type ExecRepository struct {
+	store *Store
+}
+
+// Only these fields are written by the PATCH handlers in Postgres.
+var patchableExecFields = []string{"first_name", "last_name", "email", "username", "inactive_status", "role"}
+
+// Strip the secrets that the Postgres SELECTs never return.
+func publicExec(exec models.Exec) models.Exec {
+	exec.Password = ""
+	exec.PasswordChangedAt = sql.NullString{}
+	exec.PasswordResetToken = sql.NullString{}
+	exec.PasswordTokenExpires = sql.NullString{}
+	exec.InviteToken = sql.NullString{}
+	exec.MagicLinkToken = sql.NullString{}
+	exec.MagicLinkExpires = sql.NullString{}
+	return exec
+}
+
+func patchableExecUpdates(updates map[string]interface{}) map[string]interface{} {
+	filtered := make(map[string]interface{})
+	for _, field := range patchableExecFields {
+		if v, ok := updates[field]; ok {
+			filtered[field] = v
+		}
+	}
+	return filtered
+}
+
+// Emulates the UNIQUE constraints on email and username.
+func execConflict(execs map[int]models.Exec, exec models.Exec, exceptID int, msg string) error {
+	if isTaken(execs, "email", exec.Email, exceptID) {
+		return conflictError("email", exec.Email, msg)
+	}
+	if isTaken(execs, "username", exec.Username, exceptID) {
+		return conflictError("username", exec.Username, msg)
+	}
+	return nil
+}
+
+func nowString() sql.NullString {
+	return sql.NullString{String: nowTimestamp(), Valid: true}
+}
+
+func (repo ExecRepository) GetExecs(opts repository.ListOptions) ([]models.Exec, int, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	execList, total := listItems(repo.store.execs, opts, execSearchColumns)
+	for i := range execList {
+		execList[i] = publicExec(execList[i])
+	}
+	return execList, total, nil
+}
+
+func (repo ExecRepository) GetOneExec(execId int) (models.Exec, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	exec, ok := repo.store.execs[execId]
+	if !ok {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	}
+	return publicExec(exec), nil
+}
+
+func (repo ExecRepository) PostExecs(newExecs []models.Exec) ([]models.Exec, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	addedExecs := make([]models.Exec, len(newExecs))
+	for i, newExec := range newExecs {
+		// Invited execs have no password until they accept the invitation.
+		if newExec.Password != "" || !newExec.InviteToken.Valid {
+			hashPassword, err := utils.HashPassword(newExec.Password)
+			if err != nil {
+				return nil, err
+			}
+			newExec.Password = hashPassword
+		}
+		addedExecs[i] = newExec
+	}
+
+	execs := copyItems(repo.store.execs)
+	for i := range addedExecs {
+		err := execConflict(execs, addedExecs[i], 0, "Error Adding execs.")
+		if err != nil {
+			return nil, err
+		}
+		addedExecs[i].ID = repo.store.newID("execs")
+		addedExecs[i].UserCreatedAt = nowString()
+		execs[addedExecs[i].ID] = addedExecs[i]
+	}
+	repo.store.execs = execs
+	return addedExecs, nil
+}
+
+func (repo ExecRepository) PatchOneExec(execId int, updates map[string]interface{}) (models.Exec, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	existingExec, ok := repo.store.execs[execId]
+	if !ok {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	}
+
+	err := applyUpdates(&existingExec, patchableExecUpdates(updates))
+	if err != nil {
+		return models.Exec{}, err
+	}
+	err = execConflict(repo.store.execs, existingExec, execId, fmt.Sprintf("Error updating Exec %d.", execId))
+	if err != nil {
+		return models.Exec{}, err
+	}
+	repo.store.execs[execId] = existingExec
+	return publicExec(existingExec), nil
+}
+
+func (repo ExecRepository) PatchExecs(updates []map[string]interface{}) ([]models.Exec, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	execs := copyItems(repo.store.execs)
+	var existingExecs []models.Exec
+	for _, update := range updates {
+		execIdStr, ok := update["id"].(string)
+		if !ok {
+			return nil, utils.TypedErrorHandler(errors.New("id should be a string"), utils.ErrValidation, "Invalid Exec ID.")
+		}
+
+		execId, err := strconv.Atoi(execIdStr)
+		if err != nil {
+			return nil, utils.TypedErrorHandler(err, utils.ErrValidation, "Invalid Exec ID.")
+		}
+
+		existingExec, ok := execs[execId]
+		if !ok {
+			return nil, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+		}
+
+		err = applyUpdates(&existingExec, patchableExecUpdates(update))
+		if err != nil {
+			return nil, err
+		}
+		err = execConflict(execs, existingExec, execId, "Error updating Execs.")
+		if err != nil {
+			return nil, err
+		}
+		execs[execId] = existingExec
+		existingExecs = append(existingExecs, publicExec(existingExec))
+	}
+
+	repo.store.execs = execs
+	return existingExecs, nil
+}
+
+func (repo ExecRepository) DeleteOneExec(execId int) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	if _, ok := repo.store.execs[execId]; !ok {
+		return utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	}
+	delete(repo.store.execs, execId)
+
+	// Same as ON DELETE CASCADE on refresh_tokens.
+	for id, token := range repo.store.refreshTokens {
+		if token.ExecID == execId {
+			delete(repo.store.refreshTokens, id)
+		}
+	}
+	delete(repo.store.mfa, execId)
+	delete(repo.store.recoveryCodes, execId)
+	delete(repo.store.passwordHistory, execId)
+	for id, passkey := range repo.store.passkeys {
+		if passkey.ExecID == execId {
+			delete(repo.store.passkeys, id)
+		}
+	}
+	for challenge, stored := range repo.store.passkeyChallenges {
+		if stored.ExecID == execId {
+			delete(repo.store.passkeyChallenges, challenge)
+		}
+	}
+	for id, session := range repo.store.sessions {
+		if session.ExecID == execId {
+			delete(repo.store.sessions, id)
+		}
+	}
+
+	// Same as ON DELETE SET NULL on api_keys.created_by.
+	for id, key := range repo.store.apiKeys {
+		if key.CreatedBy == execId {
+			key.CreatedBy = 0
+			repo.store.apiKeys[id] = key
+		}
+	}
+	return nil
+}
+
+// Caller must hold the lock.
+func (repo ExecRepository) findExec(match func(models.Exec) bool) (models.Exec, bool) {
+	for _, id := range sortedIDs(repo.store.execs) {
+		exec := repo.store.execs[id]
+		if match(exec) {
+			return exec, true
+		}
+	}
+	return models.Exec{}, false
+}
+
+func (repo ExecRepository) LoginExec(req models.Exec) (models.Exec, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	exec, ok := repo.findExec(func(e models.Exec) bool { return e.Username == req.Username })
+	if !ok {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrUnauthorized, "Incorrect Username / Password.")
+	}
+
+	if exec.InactiveStatus {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("account is inactive"), utils.ErrForbidden, "Account is inactive.")
+	}
+	return exec, nil
+}
+
+func (repo ExecRepository) UpdateExecPassword(execId int, req models.UpdatePasswordRequest) (string, string, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.store.execs[execId]
+	if !ok {
+		return "", "", utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, "User Not Found.")
+	}
+	err := utils.VerifyPassword(exec.Password, req.CurrentPassword)
+	if err != nil {
+		return "", "", err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return "", "", err
+	}
+	repo.recordPasswordHistory(execId, exec.Password)
+	exec.Password = hashedPassword
+	exec.PasswordChangedAt = nowString()
+	repo.store.execs[execId] = exec
+	SessionRepository{store: repo.store}.endExecSessions(execId)
+	return exec.Username, exec.Role, nil
+}
+
+func (repo ExecRepository) ForgotExecPassword(execEmail string) (time.Duration, string, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.findExec(func(e models.Exec) bool { return e.Email == execEmail })
+	if !ok {
+		return 0, "", utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, "User Not Found.")
+	}
+
+	duration, err := strconv.Atoi(os.Getenv("RESET_TOKEN_EXP_DURATION"))
+	if err != nil {
+		return 0, "", utils.ErrorHandler(err, "Some error occured.")
+	}
+	mins := time.Duration(duration)
+	expiry := time.Now().Add(mins * time.Minute)
+
+	token, hashedTokenString, err := utils.GenerateHashedToken()
+	if err != nil {
+		return 0, "", utils.ErrorHandler(err, "Failed to send Password reset email.")
+	}
+
+	exec.PasswordResetToken = sql.NullString{String: hashedTokenString, Valid: true}
+	exec.PasswordTokenExpires = sql.NullString{String: expiry.Format(time.RFC3339Nano), Valid: true}
+	repo.store.execs[exec.ID] = exec
+	return mins, token, nil
+}
+
+func (repo ExecRepository) ResetPassword(hashedTokenString string, hashedPwd string) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.findExec(func(e models.Exec) bool {
+		if !e.PasswordResetToken.Valid || e.PasswordResetToken.String != hashedTokenString {
+			return false
+		}
+		expiry, err := time.Parse(time.RFC3339Nano, e.PasswordTokenExpires.String)
+		return err == nil && expiry.After(time.Now())
+	})
+	if !ok {
+		return utils.TypedErrorHandler(errors.New("invalid reset token"), utils.ErrUnauthorized, "Invalid / Expired Reset Code.")
+	}
+
+	repo.recordPasswordHistory(exec.ID, exec.Password)
+	exec.Password = hashedPwd
+	exec.PasswordResetToken = sql.NullString{}
+	exec.PasswordTokenExpires = sql.NullString{}
+	exec.PasswordChangedAt = nowString()
+	// Proving access to the email also lifts a lockout.
+	exec.FailedLoginAttempts = 0
+	exec.LastFailedLoginAt = sql.NullString{}
+	exec.LockedUntil = sql.NullString{}
+	repo.store.execs[exec.ID] = exec
+	SessionRepository{store: repo.store}.endExecSessions(exec.ID)
+	return nil
+}
+
+func (repo ExecRepository) GetExecAuthState(execId int) (models.Exec, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	exec, ok := repo.store.execs[execId]
+	if !ok {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, "User Not Found.")
+	}
+	return models.Exec{ID: exec.ID, InactiveStatus: exec.InactiveStatus, Role: exec.Role, PasswordChangedAt: exec.PasswordChangedAt}, nil
+}
+
+func (repo ExecRepository) RecordFailedLogin(execId int, at time.Time, since time.Time) (int, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.store.execs[execId]
+	if !ok {
+		return 0, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, "User Not Found.")
+	}
+	lastFailure, err := time.Parse(time.RFC3339Nano, exec.LastFailedLoginAt.String)
+	if !exec.LastFailedLoginAt.Valid || err != nil || lastFailure.Before(since) {
+		exec.FailedLoginAttempts = 0
+	}
+	exec.FailedLoginAttempts++
+	exec.LastFailedLoginAt = sql.NullString{String: at.UTC().Format(time.RFC3339Nano), Valid: true}
+	repo.store.execs[execId] = exec
+	return exec.FailedLoginAttempts, nil
+}
+
+func (repo ExecRepository) LockExec(execId int, until time.Time) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.store.execs[execId]
+	if !ok {
+		return utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, "User Not Found.")
+	}
+	exec.LockedUntil = sql.NullString{String: until.UTC().Format(time.RFC3339Nano), Valid: true}
+	repo.store.execs[execId] = exec
+	return nil
+}
+
+func (repo ExecRepository) ResetFailedLogins(execId int) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.store.execs[execId]
+	if !ok {
+		return utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	}
+	exec.FailedLoginAttempts = 0
+	exec.LastFailedLoginAt = sql.NullString{}
+	exec.LockedUntil = sql.NullString{}
+	repo.store.execs[execId] = exec
+	return nil
+}
+
+func (repo ExecRepository) RehashPassword(execId int, oldHash string, newHash string) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.store.execs[execId]
+	if !ok || exec.Password != oldHash {
+		return nil
+	}
+	exec.Password = newHash
+	repo.store.execs[execId] = exec
+	return nil
+}
+
+func (repo ExecRepository) GetPasswordHistory(execId int, limit int) ([]string, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	exec, ok := repo.store.execs[execId]
+	if !ok {
+		return nil, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, "User Not Found.")
+	}
+	hashes := []string{exec.Password}
+	previous := repo.store.passwordHistory[execId]
+	if limit > 1 {
+		hashes = append(hashes, previous[:min(limit-1, len(previous))]...)
+	}
+	return hashes, nil
+}
+
+func (repo ExecRepository) GetExecByResetToken(hashedTokenString string) (models.Exec, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	exec, ok := repo.findExec(func(e models.Exec) bool {
+		if !e.PasswordResetToken.Valid || e.PasswordResetToken.String != hashedTokenString {
+			return false
+		}
+		expiry, err := time.Parse(time.RFC3339Nano, e.PasswordTokenExpires.String)
+		return err == nil && expiry.After(time.Now())
+	})
+	if !ok {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("invalid reset token"), utils.ErrUnauthorized, "Invalid / Expired Reset Code.")
+	}
+	return models.Exec{ID: exec.ID, Username: exec.Username, Email: exec.Email}, nil
+}
+
+func (repo ExecRepository) ReissueExecInvite(execId int, hashedTokenString string, expiresAt time.Time) (models.Exec, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.store.execs[execId]
+	if !ok {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	}
+	if exec.Password != "" {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("invitation already accepted"), utils.ErrConflict, "Exec has already set a password.")
+	}
+
+	exec.InviteToken = sql.NullString{String: hashedTokenString, Valid: true}
+	exec.InviteTokenExpires = sql.NullString{String: expiresAt.UTC().Format(time.RFC3339Nano), Valid: true}
+	repo.store.execs[execId] = exec
+	return models.Exec{ID: exec.ID, FirstName: exec.FirstName, Username: exec.Username, Email: exec.Email, InviteTokenExpires: exec.InviteTokenExpires}, nil
+}
+
+func (repo ExecRepository) GetExecByInviteToken(hashedTokenString string) (models.Exec, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	exec, ok := repo.findInvitedExec(hashedTokenString)
+	if !ok {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("invalid invite token"), utils.ErrUnauthorized, "Invalid / Expired Invitation Code.")
+	}
+	return models.Exec{ID: exec.ID, Username: exec.Username, Email: exec.Email}, nil
+}
+
+func (repo ExecRepository) AcceptExecInvite(hashedTokenString string, hashedPwd string) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.findInvitedExec(hashedTokenString)
+	if !ok {
+		return utils.TypedErrorHandler(errors.New("invalid invite token"), utils.ErrUnauthorized, "Invalid / Expired Invitation Code.")
+	}
+
+	exec.Password = hashedPwd
+	exec.PasswordChangedAt = nowString()
+	exec.InviteToken = sql.NullString{}
+	exec.InviteTokenExpires = sql.NullString{}
+	repo.store.execs[exec.ID] = exec
+	return nil
+}
+
+// Setting a password another way, e.g. through a reset, also ends the invitation.
+// Caller must hold the lock.
+func (repo ExecRepository) findInvitedExec(hashedTokenString string) (models.Exec, bool) {
+	return repo.findExec(func(e models.Exec) bool {
+		if e.Password != "" || !e.InviteToken.Valid || e.InviteToken.String != hashedTokenString {
+			return false
+		}
+		expiry, err := time.Parse(time.RFC3339Nano, e.InviteTokenExpires.String)
+		return err == nil && expiry.After(time.Now())
+	})
+}
+
+func (repo ExecRepository) CreateMagicLink(execEmail string, hashedTokenString string, expiresAt time.Time) (models.Exec, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.findExec(func(e models.Exec) bool { return strings.EqualFold(e.Email, execEmail) })
+	if !ok {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, "User Not Found.")
+	}
+	if exec.InactiveStatus {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("account is inactive"), utils.ErrForbidden, "Account is inactive.")
+	}
+
+	exec.MagicLinkToken = sql.NullString{String: hashedTokenString, Valid: true}
+	exec.MagicLinkExpires = sql.NullString{String: expiresAt.UTC().Format(time.RFC3339Nano), Valid: true}
+	repo.store.execs[exec.ID] = exec
+	return models.Exec{ID: exec.ID, FirstName: exec.FirstName, Username: exec.Username, Email: exec.Email}, nil
+}
+
+func (repo ExecRepository) ConsumeMagicLink(hashedTokenString string) (models.Exec, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	exec, ok := repo.findExec(func(e models.Exec) bool {
+		if !e.MagicLinkToken.Valid || e.MagicLinkToken.String != hashedTokenString || e.Password == "" {
+			return false
+		}
+		if e.LockedUntil.Valid {
+			lockedUntil, err := time.Parse(time.RFC3339Nano, e.LockedUntil.String)
+			if err != nil || !lockedUntil.Before(time.Now()) {
+				return false
+			}
+		}
+		expiry, err := time.Parse(time.RFC3339Nano, e.MagicLinkExpires.String)
+		return err == nil && expiry.After(time.Now())
+	})
+	if !ok {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("invalid magic link token"), utils.ErrUnauthorized, "Invalid / Expired Login Link.")
+	}
+
+	exec.MagicLinkToken = sql.NullString{}
+	exec.MagicLinkExpires = sql.NullString{}
+	repo.store.execs[exec.ID] = exec
+
+	// Same check as LoginExec, the account may have been deactivated since the link was sent.
+	if exec.InactiveStatus {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("account is inactive"), utils.ErrForbidden, "Account is inactive.")
+	}
+	return publicExec(exec), nil
+}
+
+// Keeps the replaced hash of a password change, dropping the ones beyond the history size.
+// Caller must hold the write lock.
+func (repo ExecRepository) recordPasswordHistory(execId int, oldHash string) {
+	// Execs who set their first password through a reset had no previous one.
+	if oldHash == "" {
+		return
+	}
+	history := append([]string{oldHash}, repo.store.passwordHistory[execId]...)
+	keep := max(utils.CurrentPasswordPolicy().History-1, 0)
+	repo.store.passwordHistory[execId] = history[:min(keep, len(history))]
+}
@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// MFA state is kept apart from the execs map, keyed by exec id, with recovery code
+// hashes mapped to whether they were used.
+type MFARepository struct {
+	store *Store
+}
+
+// Caller must hold the lock.
+func (repo MFARepository) execExists(execId int) error {
+	if _, ok := repo.store.execs[execId]; !ok {
+		return utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	}
+	return nil
+}
+
+func (repo MFARepository) GetMFA(execId int) (models.ExecMFA, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	err := repo.execExists(execId)
+	if err != nil {
+		return models.ExecMFA{}, err
+	}
+	mfa := repo.store.mfa[execId]
+	mfa.ExecID = execId
+	mfa.RecoveryCodesLeft = 0
+	for _, used := range repo.store.recoveryCodes[execId] {
+		if !used {
+			mfa.RecoveryCodesLeft++
+		}
+	}
+	return mfa, nil
+}
+
+func (repo MFARepository) SetMFASecret(execId int, secret string) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	err := repo.execExists(execId)
+	if err != nil {
+		return err
+	}
+	if repo.store.mfa[execId].Enabled {
+		return utils.TypedErrorHandler(errors.New("mfa already enabled"), utils.ErrConflict, "MFA is already enabled.")
+	}
+	repo.store.mfa[execId] = models.ExecMFA{ExecID: execId, Secret: secret}
+	return nil
+}
+
+func (repo MFARepository) EnableMFA(execId int, recoveryCodeHashes []string) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	mfa, ok := repo.store.mfa[execId]
+	if !ok || mfa.Secret == "" {
+		return utils.TypedErrorHandler(errors.New("no mfa secret"), utils.ErrValidation, "MFA enrollment not started.")
+	}
+	mfa.Enabled = true
+	repo.store.mfa[execId] = mfa
+	repo.replaceRecoveryCodes(execId, recoveryCodeHashes)
+	return nil
+}
+
+func (repo MFARepository) DisableMFA(execId int) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	err := repo.execExists(execId)
+	if err != nil {
+		return err
+	}
+	delete(repo.store.mfa, execId)
+	delete(repo.store.recoveryCodes, execId)
+	return nil
+}
+
+func (repo MFARepository) UseTOTPStep(execId int, step int64) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	mfa, ok := repo.store.mfa[execId]
+	if !ok || step <= mfa.LastStep {
+		return utils.TypedErrorHandler(errors.New("totp step already used"), utils.ErrUnauthorized, "MFA code already used, wait for the next one.")
+	}
+	mfa.LastStep = step
+	repo.store.mfa[execId] = mfa
+	return nil
+}
+
+func (repo MFARepository) UseRecoveryCode(execId int, codeHash string) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	used, ok := repo.store.recoveryCodes[execId][codeHash]
+	if !ok || used {
+		return utils.TypedErrorHandler(errors.New("recovery code not found"), utils.ErrUnauthorized, "Invalid recovery code.")
+	}
+	repo.store.recoveryCodes[execId][codeHash] = true
+	return nil
+}
+
+func (repo MFARepository) ReplaceRecoveryCodes(execId int, codeHashes []string) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	err := repo.execExists(execId)
+	if err != nil {
+		return err
+	}
+	repo.replaceRecoveryCodes(execId, codeHashes)
+	return nil
+}
+
+// Caller must hold the write lock.
+func (repo MFARepository) replaceRecoveryCodes(execId int, codeHashes []string) {
+	codes := make(map[string]bool)
+	for _, codeHash := range codeHashes {
+		codes[codeHash] = false
+	}
+	repo.store.recoveryCodes[execId] = codes
+}
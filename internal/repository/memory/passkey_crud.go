@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+type PasskeyRepository struct {
+	store *Store
+}
+
+func (repo PasskeyRepository) CreatePasskeyChallenge(challenge models.PasskeyChallenge) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	// Mirrors the foreign key on exec_id, which is NULL for logins.
+	if _, ok := repo.store.execs[challenge.ExecID]; challenge.ExecID != 0 && !ok {
+		return conflictError("exec_id", fmt.Sprint(challenge.ExecID), "Error creating passkey challenge.")
+	}
+	if _, ok := repo.store.passkeyChallenges[challenge.Challenge]; ok {
+		return conflictError("challenge", challenge.Challenge, "Error creating passkey challenge.")
+	}
+
+	now := time.Now()
+	for key, stored := range repo.store.passkeyChallenges {
+		if stored.ExpiresAt.Before(now) {
+			delete(repo.store.passkeyChallenges, key)
+		}
+	}
+	challenge.ExpiresAt = challenge.ExpiresAt.UTC()
+	repo.store.passkeyChallenges[challenge.Challenge] = challenge
+	return nil
+}
+
+func (repo PasskeyRepository) ConsumePasskeyChallenge(challenge string, ceremony string) (models.PasskeyChallenge, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	stored, ok := repo.store.passkeyChallenges[challenge]
+	if !ok || stored.Ceremony != ceremony || stored.ExpiresAt.Before(time.Now()) {
+		return models.PasskeyChallenge{}, utils.TypedErrorHandler(errors.New("passkey challenge not found"), utils.ErrUnauthorized, "Invalid / Expired passkey challenge.")
+	}
+	delete(repo.store.passkeyChallenges, challenge)
+	return stored, nil
+}
+
+func (repo PasskeyRepository) CreatePasskey(passkey models.Passkey) (models.Passkey, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	// Mirrors the foreign key on exec_id and the UNIQUE constraint on credential_id.
+	if _, ok := repo.store.execs[passkey.ExecID]; !ok {
+		return models.Passkey{}, conflictError("exec_id", fmt.Sprint(passkey.ExecID), "Error registering passkey.")
+	}
+	if isTaken(repo.store.passkeys, "credential_id", passkey.CredentialID, 0) {
+		return models.Passkey{}, conflictError("credential_id", passkey.CredentialID, "Error registering passkey.")
+	}
+
+	now := time.Now().UTC()
+	passkey.ID = repo.store.newID("passkeys")
+	passkey.CreatedAt = now
+	passkey.LastUsedAt = now
+	repo.store.passkeys[passkey.ID] = passkey
+	return passkey, nil
+}
+
+func (repo PasskeyRepository) GetExecPasskeys(execId int) ([]models.Passkey, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	passkeys := make([]models.Passkey, 0)
+	for _, id := range sortedIDs(repo.store.passkeys) {
+		if passkey := repo.store.passkeys[id]; passkey.ExecID == execId {
+			passkeys = append(passkeys, passkey)
+		}
+	}
+	return passkeys, nil
+}
+
+func (repo PasskeyRepository) GetPasskeyByCredentialID(credentialId string) (models.Passkey, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	for _, passkey := range repo.store.passkeys {
+		if passkey.CredentialID == credentialId {
+			return passkey, nil
+		}
+	}
+	return models.Passkey{}, utils.TypedErrorHandler(errors.New("passkey not found"), utils.ErrUnauthorized, "Unknown passkey.")
+}
+
+func (repo PasskeyRepository) UsePasskey(passkeyId int, oldSignCount uint32, signCount uint32, usedAt time.Time) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	passkey, ok := repo.store.passkeys[passkeyId]
+	if !ok || passkey.SignCount != oldSignCount {
+		return utils.TypedErrorHandler(errors.New("sign count changed"), utils.ErrUnauthorized, "Passkey was used by another login, try again.")
+	}
+	passkey.SignCount = signCount
+	passkey.LastUsedAt = usedAt.UTC()
+	repo.store.passkeys[passkeyId] = passkey
+	return nil
+}
+
+func (repo PasskeyRepository) DeletePasskey(execId int, passkeyId int) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	passkey, ok := repo.store.passkeys[passkeyId]
+	if !ok || passkey.ExecID != execId {
+		return utils.TypedErrorHandler(errors.New("passkey not found"), utils.ErrNotFound, fmt.Sprintf("Passkey %d not found.", passkeyId))
+	}
+	delete(repo.store.passkeys, passkeyId)
+	return nil
+}
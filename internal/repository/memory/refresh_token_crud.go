@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+type RefreshTokenRepository struct {
+	store *Store
+}
+
+// Caller must hold the lock.
+func (repo RefreshTokenRepository) findToken(hashedToken string) (models.RefreshToken, bool) {
+	for _, token := range repo.store.refreshTokens {
+		if token.TokenHash == hashedToken {
+			return token, true
+		}
+	}
+	return models.RefreshToken{}, false
+}
+
+// Caller must hold the write lock.
+func (repo RefreshTokenRepository) revokeFamily(familyId string) int {
+	revoked := 0
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	for id, token := range repo.store.refreshTokens {
+		if token.FamilyID == familyId && !token.RevokedAt.Valid {
+			token.RevokedAt = now
+			repo.store.refreshTokens[id] = token
+			revoked++
+		}
+	}
+	return revoked
+}
+
+// A password change logs out every session, see endExecSessions. Caller must hold the write lock.
+func (repo RefreshTokenRepository) revokeExecTokens(execId int) {
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	for id, token := range repo.store.refreshTokens {
+		if token.ExecID == execId && !token.RevokedAt.Valid {
+			token.RevokedAt = now
+			repo.store.refreshTokens[id] = token
+		}
+	}
+}
+
+func (repo RefreshTokenRepository) CreateRefreshToken(token models.RefreshToken) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	// Mirrors the foreign key on exec_id.
+	if _, ok := repo.store.execs[token.ExecID]; !ok {
+		return conflictError("exec_id", strconv.Itoa(token.ExecID), "Error storing refresh token.")
+	}
+	if _, ok := repo.findToken(token.TokenHash); ok {
+		return conflictError("token_hash", token.TokenHash, "Error storing refresh token.")
+	}
+	token.ID = repo.store.newID("refresh_tokens")
+	repo.store.refreshTokens[token.ID] = token
+	return nil
+}
+
+func (repo RefreshTokenRepository) RotateRefreshToken(hashedToken string, next models.RefreshToken) (models.Exec, int, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	current, ok := repo.findToken(hashedToken)
+	if !ok {
+		return models.Exec{}, 0, utils.TypedErrorHandler(errors.New("refresh token not found"), utils.ErrUnauthorized, "Invalid refresh token.")
+	}
+	if current.RevokedAt.Valid {
+		return models.Exec{}, 0, utils.TypedErrorHandler(errors.New("refresh token revoked"), utils.ErrUnauthorized, "Refresh token revoked, please log in again.")
+	}
+	if current.UsedAt.Valid {
+		repo.revokeFamily(current.FamilyID)
+		delete(repo.store.sessions, current.SessionID)
+		return models.Exec{}, 0, utils.TypedErrorHandler(errors.New("refresh token reused"), utils.ErrUnauthorized, "Refresh token reuse detected, please log in again.")
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return models.Exec{}, 0, utils.TypedErrorHandler(errors.New("refresh token expired"), utils.ErrUnauthorized, "Refresh token expired, please log in again.")
+	}
+
+	exec, ok := repo.store.execs[current.ExecID]
+	if !ok {
+		return models.Exec{}, 0, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, "User Not Found.")
+	}
+	if exec.InactiveStatus {
+		return models.Exec{}, 0, utils.TypedErrorHandler(errors.New("account is inactive"), utils.ErrForbidden, "Account is inactive.")
+	}
+
+	current.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	repo.store.refreshTokens[current.ID] = current
+
+	next.ID = repo.store.newID("refresh_tokens")
+	next.ExecID = current.ExecID
+	next.FamilyID = current.FamilyID
+	next.SessionID = current.SessionID
+	repo.store.refreshTokens[next.ID] = next
+	return publicExec(exec), current.SessionID, nil
+}
+
+func (repo RefreshTokenRepository) RevokeRefreshTokenFamily(hashedToken string) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	token, ok := repo.findToken(hashedToken)
+	if !ok {
+		return utils.TypedErrorHandler(errors.New("refresh token not found"), utils.ErrNotFound, "Refresh token not found.")
+	}
+	revoked := repo.revokeFamily(token.FamilyID)
+	delete(repo.store.sessions, token.SessionID)
+	if revoked == 0 {
+		return utils.TypedErrorHandler(errors.New("refresh token not found"), utils.ErrNotFound, "Refresh token not found.")
+	}
+	return nil
+}
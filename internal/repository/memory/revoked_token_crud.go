@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+type RevokedTokenRepository struct {
+	store *Store
+}
+
+func (repo RevokedTokenRepository) RevokeToken(jti string, execId int, expiresAt time.Time) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	repo.store.revokedTokens[jti] = expiresAt
+
+	// Expired tokens are rejected anyway, so they can go.
+	for revokedJti, revokedExpiresAt := range repo.store.revokedTokens {
+		if revokedExpiresAt.Before(time.Now()) {
+			delete(repo.store.revokedTokens, revokedJti)
+		}
+	}
+	return nil
+}
+
+func (repo RevokedTokenRepository) UseToken(jti string, execId int, expiresAt time.Time) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	if _, used := repo.store.revokedTokens[jti]; used {
+		return utils.TypedErrorHandler(errors.New("token already used"), utils.ErrUnauthorized, "Token has already been used, please log in again.")
+	}
+	repo.store.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (repo RevokedTokenRepository) IsTokenRevoked(jti string) (bool, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	_, revoked := repo.store.revokedTokens[jti]
+	return revoked, nil
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Columns matched by ?q= and /search, the same ones as the Postgres search vectors.
+var (
+	teacherSearchColumns = []string{"first_name", "last_name", "email", "class", "subject"}
+	studentSearchColumns = []string{"first_name", "last_name", "email", "class"}
+	execSearchColumns    = []string{"first_name", "last_name", "email", "username"}
+)
+
+type SearchRepository struct {
+	store *Store
+}
+
+// Share of the words of model starting with one of the terms, 0 unless every term matches a word.
+// A rough stand-in for ts_rank, enough to put closer matches first.
+func searchRank(model interface{}, columns []string, terms []string) float64 {
+	var words []string
+	for _, column := range columns {
+		words = append(words, utils.SearchTerms(utils.GetColumnValue(model, column))...)
+	}
+
+	matched := 0
+	for _, term := range terms {
+		found := false
+		for _, word := range words {
+			if strings.HasPrefix(word, term) {
+				matched++
+				found = true
+			}
+		}
+		if !found {
+			return 0
+		}
+	}
+	return float64(matched) / float64(len(words))
+}
+
+func (repo SearchRepository) Search(terms []string, page int, limit int) ([]models.SearchResult, int, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	results := make([]models.SearchResult, 0)
+	for _, id := range sortedIDs(repo.store.teachers) {
+		tchr := repo.store.teachers[id]
+		rank := searchRank(tchr, teacherSearchColumns, terms)
+		if rank > 0 {
+			results = append(results, models.SearchResult{Type: "teacher", ID: tchr.ID, FirstName: tchr.FirstName, LastName: tchr.LastName, Email: tchr.Email, Class: tchr.Class, Subject: tchr.Subject, Rank: rank})
+		}
+	}
+	for _, id := range sortedIDs(repo.store.students) {
+		sdnt := repo.store.students[id]
+		rank := searchRank(sdnt, studentSearchColumns, terms)
+		if rank > 0 {
+			results = append(results, models.SearchResult{Type: "student", ID: sdnt.ID, FirstName: sdnt.FirstName, LastName: sdnt.LastName, Email: sdnt.Email, Class: sdnt.Class, Rank: rank})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Type < results[j].Type
+	})
+
+	offset := (page - 1) * limit
+	if offset >= len(results) {
+		return make([]models.SearchResult, 0), len(results), nil
+	}
+	end := min(offset+limit, len(results))
+	return results[offset:end], len(results), nil
+}
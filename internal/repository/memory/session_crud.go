@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+type SessionRepository struct {
+	store *Store
+}
+
+func (repo SessionRepository) CreateSession(session models.Session) (models.Session, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	// Mirrors the foreign key on exec_id.
+	if _, ok := repo.store.execs[session.ExecID]; !ok {
+		return models.Session{}, conflictError("exec_id", fmt.Sprint(session.ExecID), "Error creating session.")
+	}
+
+	now := time.Now().UTC()
+	for id, existing := range repo.store.sessions {
+		if existing.ExecID == session.ExecID && existing.ExpiresAt.Before(now) {
+			repo.deleteSession(id)
+		}
+	}
+
+	session.ID = repo.store.newID("sessions")
+	session.CreatedAt = now
+	session.LastSeenAt = now
+	session.ExpiresAt = session.ExpiresAt.UTC()
+	repo.store.sessions[session.ID] = session
+	return session, nil
+}
+
+func (repo SessionRepository) GetSession(sessionId int) (models.Session, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	session, ok := repo.store.sessions[sessionId]
+	if !ok || session.ExpiresAt.Before(time.Now()) {
+		return models.Session{}, utils.TypedErrorHandler(errors.New("session not found"), utils.ErrNotFound, fmt.Sprintf("Session %d not found.", sessionId))
+	}
+	return session, nil
+}
+
+func (repo SessionRepository) GetExecSessions(execId int) ([]models.Session, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	now := time.Now()
+	sessions := make([]models.Session, 0)
+	for _, id := range sortedIDs(repo.store.sessions) {
+		session := repo.store.sessions[id]
+		if session.ExecID == execId && !session.ExpiresAt.Before(now) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	// Same order as the SQL, most recently seen first.
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].ID > sessions[j].ID
+		}
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (repo SessionRepository) TouchSession(sessionId int, lastSeenAt time.Time, expiresAt time.Time) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	session, ok := repo.store.sessions[sessionId]
+	if !ok {
+		return nil
+	}
+	session.LastSeenAt = lastSeenAt.UTC()
+	if !expiresAt.IsZero() {
+		session.ExpiresAt = expiresAt.UTC()
+	}
+	repo.store.sessions[sessionId] = session
+	return nil
+}
+
+func (repo SessionRepository) DeleteSession(execId int, sessionId int) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	session, ok := repo.store.sessions[sessionId]
+	if !ok || session.ExecID != execId {
+		return utils.TypedErrorHandler(errors.New("session not found"), utils.ErrNotFound, fmt.Sprintf("Session %d not found.", sessionId))
+	}
+	repo.deleteSession(sessionId)
+	return nil
+}
+
+func (repo SessionRepository) DeleteExecSessions(execId int) (int, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	if _, ok := repo.store.execs[execId]; !ok {
+		return 0, utils.TypedErrorHandler(errors.New("exec not found"), utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	}
+	return repo.endExecSessions(execId), nil
+}
+
+// Signs the exec out everywhere, like endExecSessions in sqlconnect. Used on password
+// changes too. Caller must hold the write lock.
+func (repo SessionRepository) endExecSessions(execId int) int {
+	RefreshTokenRepository{store: repo.store}.revokeExecTokens(execId)
+	count := 0
+	for id, session := range repo.store.sessions {
+		if session.ExecID == execId {
+			repo.deleteSession(id)
+			count++
+		}
+	}
+	return count
+}
+
+// Same as ON DELETE CASCADE on refresh_tokens.session_id. Caller must hold the write lock.
+func (repo SessionRepository) deleteSession(sessionId int) {
+	delete(repo.store.sessions, sessionId)
+	for id, token := range repo.store.refreshTokens {
+		if token.SessionID == sessionId {
+			delete(repo.store.refreshTokens, id)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package memory
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// In-memory data shared by all the repositories, guarded by a single lock
+// so cross-resource reads (e.g. students of a teacher) stay consistent.
+type Store struct {
+	mu            sync.RWMutex
+	teachers      map[int]models.Teacher
+	students      map[int]models.Student
+	execs         map[int]models.Exec
+	refreshTokens map[int]models.RefreshToken
+	revokedTokens map[string]time.Time
+	mfa           map[int]models.ExecMFA
+	recoveryCodes map[int]map[string]bool
+	apiKeys       map[int]models.APIKey
+	sessions      map[int]models.Session
+	// Previous password hashes of each exec, newest first.
+	passwordHistory   map[int][]string
+	passkeys          map[int]models.Passkey
+	passkeyChallenges map[string]models.PasskeyChallenge
+	nextID            map[string]int
+}
+
+func NewStore() *Store {
+	return &Store{
+		teachers:          make(map[int]models.Teacher),
+		students:          make(map[int]models.Student),
+		execs:             make(map[int]models.Exec),
+		refreshTokens:     make(map[int]models.RefreshToken),
+		revokedTokens:     make(map[string]time.Time),
+		mfa:               make(map[int]models.ExecMFA),
+		recoveryCodes:     make(map[int]map[string]bool),
+		apiKeys:           make(map[int]models.APIKey),
+		sessions:          make(map[int]models.Session),
+		passwordHistory:   make(map[int][]string),
+		passkeys:          make(map[int]models.Passkey),
+		passkeyChallenges: make(map[string]models.PasskeyChallenge),
+		nextID:            make(map[string]int),
+	}
+}
+
+// In-memory repositories backed by a fresh Store, for local runs and unit tests.
+func NewRepositories() repository.Repositories {
+	store := NewStore()
+	return repository.Repositories{
+		Teachers:      TeacherRepository{store: store},
+		Students:      StudentRepository{store: store},
+		Execs:         ExecRepository{store: store},
+		Search:        SearchRepository{store: store},
+		RefreshTokens: RefreshTokenRepository{store: store},
+		RevokedTokens: RevokedTokenRepository{store: store},
+		MFA:           MFARepository{store: store},
+		APIKeys:       APIKeyRepository{store: store},
+		Sessions:      SessionRepository{store: store},
+		Passkeys:      PasskeyRepository{store: store},
+	}
+}
+
+// Mimics a SERIAL column. Caller must hold the write lock.
+func (s *Store) newID(table string) int {
+	s.nextID[table]++
+	return s.nextID[table]
+}
+
+// Returns the item ids in ascending order, which is the default row order in Postgres.
+func sortedIDs[T any](items map[int]T) []int {
+	ids := make([]int, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Filters, sorts and paginates items the same way the Postgres list queries do. Also returns
+// the number of items matching the filters.
+// searchColumns are the columns matched against opts.Search.
+func listItems[T any](items map[int]T, opts repository.ListOptions, searchColumns []string) ([]T, int) {
+	list := make([]T, 0)
+	for _, id := range sortedIDs(items) {
+		item := items[id]
+		if matchesFilters(item, opts.Filters) && (len(opts.Search) == 0 || searchRank(item, searchColumns, opts.Search) > 0) {
+			list = append(list, item)
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		for _, sortField := range opts.Sort {
+			a, b := utils.GetColumnValue(list[i], sortField.Field), utils.GetColumnValue(list[j], sortField.Field)
+			if a == b {
+				continue
+			}
+			if sortField.Order == "desc" {
+				return a > b
+			}
+			return a < b
+		}
+		return false
+	})
+
+	total := len(list)
+	if opts.After != nil {
+		list = itemsAfter(list, opts)
+	}
+
+	offset := (opts.Page - 1) * opts.Limit
+	if offset < 0 || offset >= len(list) {
+		return make([]T, 0), total
+	}
+	end := offset + opts.Limit
+	if end > len(list) {
+		end = len(list)
+	}
+	return list[offset:end], total
+}
+
+// Timestamps are stored in UTC, so they compare the same way as in the filters.
+func nowTimestamp() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// Keeps the items of a sorted list that come after the cursor, same rules as the SQL keyset.
+func itemsAfter[T any](list []T, opts repository.ListOptions) []T {
+	after := make([]T, 0)
+	for _, item := range list {
+		if isAfterCursor(item, opts) {
+			after = append(after, item)
+		}
+	}
+	return after
+}
+
+func isAfterCursor(model interface{}, opts repository.ListOptions) bool {
+	for i, sortField := range opts.Sort {
+		value := utils.GetColumnValue(model, sortField.Field)
+		if value == opts.After.Values[i] {
+			continue
+		}
+		if sortField.Order == "desc" {
+			return value < opts.After.Values[i]
+		}
+		return value > opts.After.Values[i]
+	}
+	id, _ := strconv.Atoi(utils.GetColumnValue(model, "id"))
+	return id > opts.After.ID
+}
+
+func matchesFilters(model interface{}, filters []repository.Filter) bool {
+	for _, filter := range filters {
+		if matchesFilter(utils.GetColumnValue(model, filter.Field), filter) == filter.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Same semantics as the SQL built by sqlconnect, see repository.Filter.
+func matchesFilter(value string, filter repository.Filter) bool {
+	switch filter.Operator {
+	case repository.OpIn:
+		for _, v := range filter.Values {
+			if compareValues(value, v) == 0 {
+				return true
+			}
+		}
+		return false
+	case repository.OpLike:
+		return likePattern(filter.Values[0]).MatchString(value)
+	}
+
+	order := compareValues(value, filter.Values[0])
+	switch filter.Operator {
+	case repository.OpNe:
+		return order != 0
+	case repository.OpGt:
+		return order > 0
+	case repository.OpGte:
+		return order >= 0
+	case repository.OpLt:
+		return order < 0
+	case repository.OpLte:
+		return order <= 0
+	}
+	return order == 0
+}
+
+// Compares as numbers or timestamps when both sides parse as such, as strings otherwise.
+func compareValues(a, b string) int {
+	aInt, errA := strconv.Atoi(a)
+	bInt, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return cmp.Compare(aInt, bInt)
+	}
+	aTime, errA := time.Parse(time.RFC3339Nano, a)
+	bTime, errB := time.Parse(time.RFC3339Nano, b)
+	if errA == nil && errB == nil {
+		return aTime.Compare(bTime)
+	}
+	return strings.Compare(a, b)
+}
+
+// Turns a LIKE pattern into a case-insensitive regexp, % matches any run and _ a single character.
+func likePattern(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	for _, char := range pattern {
+		switch char {
+		case '%':
+			expr.WriteString(".*")
+		case '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// Apply updates keyed by json field name onto model (a pointer) using reflect.
+func applyUpdates(model interface{}, updates map[string]interface{}) error {
+	modelVal := reflect.ValueOf(model).Elem()
+	modelValType := modelVal.Type()
+
+	for k, v := range updates {
+		if k == "id" {
+			continue // Skip the id field.
+		}
+		for i := 0; i < modelVal.NumField(); i++ {
+			field := modelValType.Field(i)
+			json_field := field.Tag.Get("json")
+
+			// Check whether such key exists in fields and set its value to v
+			if json_field == k+",omitempty" && modelVal.Field(i).CanSet() {
+				if v == nil || !reflect.ValueOf(v).Type().ConvertibleTo(modelVal.Field(i).Type()) {
+					return utils.TypedErrorHandler(fmt.Errorf("cannot convert %T", v), utils.ErrValidation, fmt.Sprintf("Invalid value for field %s.", k))
+				}
+				modelVal.Field(i).Set(reflect.ValueOf(v).Convert(modelVal.Field(i).Type()))
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Emulates a UNIQUE constraint : true if another item (not exceptID) already has value in column.
+func isTaken[T any](items map[int]T, column string, value string, exceptID int) bool {
+	for id, item := range items {
+		if id != exceptID && utils.GetColumnValue(item, column) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Batch writes work on a copy, which is swapped in only if every item succeeds, like a Postgres transaction.
+func copyItems[T any](items map[int]T) map[int]T {
+	copied := make(map[int]T, len(items))
+	for id, item := range items {
+		copied[id] = item
+	}
+	return copied
+}
+
+func conflictError(column string, value string, msg string) error {
+	return utils.TypedErrorHandler(fmt.Errorf("duplicate %s %q", column, value), utils.ErrConflict, msg)
+}
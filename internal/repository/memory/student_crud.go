@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+type StudentRepository struct {
+	store *Store
+}
+
+func (repo StudentRepository) GetStudents(opts repository.ListOptions) ([]models.Student, int, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	studentList, total := listItems(repo.store.students, opts, studentSearchColumns)
+	return studentList, total, nil
+}
+
+func (repo StudentRepository) GetOneStudent(studentId int) (models.Student, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	sdnt, ok := repo.store.students[studentId]
+	if !ok {
+		return models.Student{}, utils.TypedErrorHandler(errors.New("student not found"), utils.ErrNotFound, fmt.Sprintf("Student %d not found.", studentId))
+	}
+	return sdnt, nil
+}
+
+func (repo StudentRepository) PostStudents(newStudents []models.Student) ([]models.Student, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	students := copyItems(repo.store.students)
+	addedStudents := make([]models.Student, len(newStudents))
+	for i, newStudent := range newStudents {
+		if isTaken(students, "email", newStudent.Email, 0) {
+			return nil, conflictError("email", newStudent.Email, "Error Adding students.")
+		}
+		newStudent.ID = repo.store.newID("students")
+		newStudent.CreatedAt = nowTimestamp()
+		students[newStudent.ID] = newStudent
+		addedStudents[i] = newStudent
+	}
+	repo.store.students = students
+	return addedStudents, nil
+}
+
+func (repo StudentRepository) PutOneStudent(studentId int, updatedSdnt models.Student) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	existingSdnt, ok := repo.store.students[studentId]
+	if !ok {
+		return utils.TypedErrorHandler(errors.New("student not found"), utils.ErrNotFound, fmt.Sprintf("Student %d not found.", studentId))
+	}
+	if isTaken(repo.store.students, "email", updatedSdnt.Email, studentId) {
+		return conflictError("email", updatedSdnt.Email, fmt.Sprintf("Error updating Student %d.", studentId))
+	}
+	updatedSdnt.ID = studentId
+	updatedSdnt.CreatedAt = existingSdnt.CreatedAt
+	repo.store.students[studentId] = updatedSdnt
+	return nil
+}
+
+func (repo StudentRepository) PatchOneStudent(studentId int, updates map[string]interface{}) (models.Student, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	existingSdnt, ok := repo.store.students[studentId]
+	if !ok {
+		return models.Student{}, utils.TypedErrorHandler(errors.New("student not found"), utils.ErrNotFound, fmt.Sprintf("Student %d not found.", studentId))
+	}
+
+	err := applyUpdates(&existingSdnt, updates)
+	if err != nil {
+		return models.Student{}, err
+	}
+	if isTaken(repo.store.students, "email", existingSdnt.Email, studentId) {
+		return models.Student{}, conflictError("email", existingSdnt.Email, fmt.Sprintf("Error updating Student %d.", studentId))
+	}
+	repo.store.students[studentId] = existingSdnt
+	return existingSdnt, nil
+}
+
+func (repo StudentRepository) PatchStudents(updates []map[string]interface{}) ([]models.Student, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	students := copyItems(repo.store.students)
+	var existingSdnts []models.Student
+	for _, update := range updates {
+		sdntIdStr, ok := update["id"].(string)
+		if !ok {
+			return nil, utils.TypedErrorHandler(errors.New("id should be a string"), utils.ErrValidation, "Invalid Student ID.")
+		}
+
+		sdntId, err := strconv.Atoi(sdntIdStr)
+		if err != nil {
+			return nil, utils.TypedErrorHandler(err, utils.ErrValidation, "Invalid Student ID.")
+		}
+
+		existingSdnt, ok := students[sdntId]
+		if !ok {
+			return nil, utils.TypedErrorHandler(errors.New("student not found"), utils.ErrNotFound, fmt.Sprintf("Student %d not found.", sdntId))
+		}
+
+		err = applyUpdates(&existingSdnt, update)
+		if err != nil {
+			return nil, err
+		}
+		if isTaken(students, "email", existingSdnt.Email, sdntId) {
+			return nil, conflictError("email", existingSdnt.Email, "Error updating Students.")
+		}
+		students[sdntId] = existingSdnt
+		existingSdnts = append(existingSdnts, existingSdnt)
+	}
+
+	repo.store.students = students
+	return existingSdnts, nil
+}
+
+func (repo StudentRepository) DeleteOneStudent(studentId int) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	if _, ok := repo.store.students[studentId]; !ok {
+		return utils.TypedErrorHandler(errors.New("student not found"), utils.ErrNotFound, fmt.Sprintf("Student %d not found.", studentId))
+	}
+	delete(repo.store.students, studentId)
+	return nil
+}
+
+func (repo StudentRepository) DeleteStudents(ids []int) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	for _, studentId := range ids {
+		if _, ok := repo.store.students[studentId]; !ok {
+			return utils.TypedErrorHandler(errors.New("student not found"), utils.ErrNotFound, fmt.Sprintf("Student %d not found.", studentId))
+		}
+	}
+	for _, studentId := range ids {
+		delete(repo.store.students, studentId)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+type TeacherRepository struct {
+	store *Store
+}
+
+func (repo TeacherRepository) GetTeachers(opts repository.ListOptions) ([]models.Teacher, int, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	teacherList, total := listItems(repo.store.teachers, opts, teacherSearchColumns)
+	return teacherList, total, nil
+}
+
+func (repo TeacherRepository) GetOneTeacher(teacherId int) (models.Teacher, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	tchr, ok := repo.store.teachers[teacherId]
+	if !ok {
+		return models.Teacher{}, utils.TypedErrorHandler(errors.New("teacher not found"), utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", teacherId))
+	}
+	return tchr, nil
+}
+
+func (repo TeacherRepository) GetStudentsByTeacherID(teacherId int) ([]models.Student, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	students := []models.Student{}
+	tchr, ok := repo.store.teachers[teacherId]
+	if !ok {
+		return students, nil
+	}
+	for _, id := range sortedIDs(repo.store.students) {
+		student := repo.store.students[id]
+		if student.Class == tchr.Class {
+			students = append(students, student)
+		}
+	}
+	return students, nil
+}
+
+func (repo TeacherRepository) GetStudentCountByTeacherID(teacherId int) (int, error) {
+	students, err := repo.GetStudentsByTeacherID(teacherId)
+	if err != nil {
+		return 0, err
+	}
+	return len(students), nil
+}
+
+func (repo TeacherRepository) PostTeachers(newTeachers []models.Teacher) ([]models.Teacher, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	teachers := copyItems(repo.store.teachers)
+	addedTeachers := make([]models.Teacher, len(newTeachers))
+	for i, newTeacher := range newTeachers {
+		if isTaken(teachers, "email", newTeacher.Email, 0) {
+			return nil, conflictError("email", newTeacher.Email, "Error Adding teachers.")
+		}
+		newTeacher.ID = repo.store.newID("teachers")
+		newTeacher.CreatedAt = nowTimestamp()
+		teachers[newTeacher.ID] = newTeacher
+		addedTeachers[i] = newTeacher
+	}
+	repo.store.teachers = teachers
+	return addedTeachers, nil
+}
+
+func (repo TeacherRepository) PutOneTeacher(teacherId int, updatedTchr models.Teacher) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	existingTchr, ok := repo.store.teachers[teacherId]
+	if !ok {
+		return utils.TypedErrorHandler(errors.New("teacher not found"), utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", teacherId))
+	}
+	if isTaken(repo.store.teachers, "email", updatedTchr.Email, teacherId) {
+		return conflictError("email", updatedTchr.Email, fmt.Sprintf("Error updating Teacher %d.", teacherId))
+	}
+	updatedTchr.ID = teacherId
+	updatedTchr.CreatedAt = existingTchr.CreatedAt
+	repo.store.teachers[teacherId] = updatedTchr
+	return nil
+}
+
+func (repo TeacherRepository) PatchOneTeacher(teacherId int, updates map[string]interface{}) (models.Teacher, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	existingTchr, ok := repo.store.teachers[teacherId]
+	if !ok {
+		return models.Teacher{}, utils.TypedErrorHandler(errors.New("teacher not found"), utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", teacherId))
+	}
+
+	err := applyUpdates(&existingTchr, updates)
+	if err != nil {
+		return models.Teacher{}, err
+	}
+	if isTaken(repo.store.teachers, "email", existingTchr.Email, teacherId) {
+		return models.Teacher{}, conflictError("email", existingTchr.Email, fmt.Sprintf("Error updating Teacher %d.", teacherId))
+	}
+	repo.store.teachers[teacherId] = existingTchr
+	return existingTchr, nil
+}
+
+func (repo TeacherRepository) PatchTeachers(updates []map[string]interface{}) ([]models.Teacher, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	teachers := copyItems(repo.store.teachers)
+	var existingTchrs []models.Teacher
+	for _, update := range updates {
+		tchrIdStr, ok := update["id"].(string)
+		if !ok {
+			return nil, utils.TypedErrorHandler(errors.New("id should be a string"), utils.ErrValidation, "Invalid Teacher ID.")
+		}
+
+		tchrId, err := strconv.Atoi(tchrIdStr)
+		if err != nil {
+			return nil, utils.TypedErrorHandler(err, utils.ErrValidation, "Invalid Teacher ID.")
+		}
+
+		existingTchr, ok := teachers[tchrId]
+		if !ok {
+			return nil, utils.TypedErrorHandler(errors.New("teacher not found"), utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", tchrId))
+		}
+
+		err = applyUpdates(&existingTchr, update)
+		if err != nil {
+			return nil, err
+		}
+		if isTaken(teachers, "email", existingTchr.Email, tchrId) {
+			return nil, conflictError("email", existingTchr.Email, "Error updating Teachers.")
+		}
+		teachers[tchrId] = existingTchr
+		existingTchrs = append(existingTchrs, existingTchr)
+	}
+
+	repo.store.teachers = teachers
+	return existingTchrs, nil
+}
+
+func (repo TeacherRepository) DeleteOneTeacher(teacherId int) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	if _, ok := repo.store.teachers[teacherId]; !ok {
+		return utils.TypedErrorHandler(errors.New("teacher not found"), utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", teacherId))
+	}
+	delete(repo.store.teachers, teacherId)
+	return nil
+}
+
+func (repo TeacherRepository) DeleteTeachers(ids []int) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	for _, teacherId := range ids {
+		if _, ok := repo.store.teachers[teacherId]; !ok {
+			return utils.TypedErrorHandler(errors.New("teacher not found"), utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", teacherId))
+		}
+	}
+	for _, teacherId := range ids {
+		delete(repo.store.teachers, teacherId)
+	}
+	return nil
+}
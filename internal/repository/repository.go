@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+)
+
+// Filter operators, parsed from field[op]=value. A plain field=value is eq.
+const (
+	OpEq   = "eq"
+	OpNe   = "ne"
+	OpLike = "like"
+	OpIn   = "in"
+	OpGt   = "gt"
+	OpGte  = "gte"
+	OpLt   = "lt"
+	OpLte  = "lte"
+)
+
+// Filter on a whitelisted column. Values holds a single value, except for in.
+// like takes a pattern with the % and _ wildcards and ignores case.
+// Negate excludes the matching rows instead, e.g. first_name[not_like]=A%.
+type Filter struct {
+	Field    string
+	Operator string
+	Values   []string
+	Negate   bool
+}
+
+// Sort on a whitelisted column, Order is either asc or desc.
+type SortField struct {
+	Field string
+	Order string
+}
+
+// Position right after the last row of the previous page, for keyset pagination.
+// Values holds the sort column values of that row, in Sort order, ID breaks ties.
+type Cursor struct {
+	Values []string
+	ID     int
+}
+
+// Options for list endpoints, built by the handlers from the query params.
+// When After is set, rows are listed after the cursor instead of skipping pages.
+// Search holds the terms of ?q=, rows must have a word starting with each of them.
+type ListOptions struct {
+	Filters []Filter
+	Sort    []SortField
+	Page    int
+	Limit   int
+	After   *Cursor
+	Search  []string
+}
+
+type TeacherRepository interface {
+	GetTeachers(opts ListOptions) ([]models.Teacher, int, error)
+	GetOneTeacher(teacherId int) (models.Teacher, error)
+	GetStudentsByTeacherID(teacherId int) ([]models.Student, error)
+	GetStudentCountByTeacherID(teacherId int) (int, error)
+	PostTeachers(newTeachers []models.Teacher) ([]models.Teacher, error)
+	PutOneTeacher(teacherId int, updatedTchr models.Teacher) error
+	PatchOneTeacher(teacherId int, updates map[string]interface{}) (models.Teacher, error)
+	PatchTeachers(updates []map[string]interface{}) ([]models.Teacher, error)
+	DeleteOneTeacher(teacherId int) error
+	DeleteTeachers(ids []int) error
+}
+
+type StudentRepository interface {
+	GetStudents(opts ListOptions) ([]models.Student, int, error)
+	GetOneStudent(studentId int) (models.Student, error)
+	PostStudents(newStudents []models.Student) ([]models.Student, error)
+	PutOneStudent(studentId int, updatedSdnt models.Student) error
+	PatchOneStudent(studentId int, updates map[string]interface{}) (models.Student, error)
+	PatchStudents(updates []map[string]interface{}) ([]models.Student, error)
+	DeleteOneStudent(studentId int) error
+	DeleteStudents(ids []int) error
+}
+
+type ExecRepository interface {
+	GetExecs(opts ListOptions) ([]models.Exec, int, error)
+	GetOneExec(execId int) (models.Exec, error)
+	PostExecs(newExecs []models.Exec) ([]models.Exec, error)
+	PatchOneExec(execId int, updates map[string]interface{}) (models.Exec, error)
+	PatchExecs(updates []map[string]interface{}) ([]models.Exec, error)
+	DeleteOneExec(execId int) error
+	LoginExec(req models.Exec) (models.Exec, error)
+	UpdateExecPassword(execId int, req models.UpdatePasswordRequest) (string, string, error)
+	ForgotExecPassword(execEmail string) (time.Duration, string, error)
+	ResetPassword(hashedTokenString string, hashedPwd string) error
+	// Returns the fields JWT_MW checks on every request: id, role, inactive_status, password_changed_at.
+	GetExecAuthState(execId int) (models.Exec, error)
+	// Counts a failed login at the given time and returns the number of consecutive failures.
+	// When the previous failure was before since the count starts over.
+	RecordFailedLogin(execId int, at time.Time, since time.Time) (int, error)
+	LockExec(execId int, until time.Time) error
+	// Clears the failed logins and any lock, after a successful login or by an admin.
+	ResetFailedLogins(execId int) error
+	// Replaces the password hash with one of the same password using the current parameters.
+	// Only done while the stored hash is still oldHash, and not counted as a password change.
+	RehashPassword(execId int, oldHash string, newHash string) error
+	// Returns the current password hash followed by up to limit-1 previous ones, newest first.
+	GetPasswordHistory(execId int, limit int) ([]string, error)
+	// Returns the exec a valid, unexpired reset code belongs to.
+	GetExecByResetToken(hashedTokenString string) (models.Exec, error)
+	// Replaces the invitation code of an exec who hasn't set a password yet.
+	ReissueExecInvite(execId int, hashedTokenString string, expiresAt time.Time) (models.Exec, error)
+	// Returns the exec a valid, unexpired invitation code belongs to.
+	GetExecByInviteToken(hashedTokenString string) (models.Exec, error)
+	// Sets the first password of an invited exec, using up the invitation code.
+	AcceptExecInvite(hashedTokenString string, hashedPwd string) error
+	// Stores a magic login link code for the exec with this email, compared case-insensitively,
+	// replacing any previous one.
+	CreateMagicLink(execEmail string, hashedTokenString string, expiresAt time.Time) (models.Exec, error)
+	// Uses up a valid, unexpired magic link code and returns the exec to log in. Locked execs and
+	// invited execs without a password can't use one.
+	ConsumeMagicLink(hashedTokenString string) (models.Exec, error)
+}
+
+// Refresh tokens are looked up by the sha256 hex hash of the token sent by the client.
+type RefreshTokenRepository interface {
+	CreateRefreshToken(token models.RefreshToken) error
+	// Marks the token used and stores next in its family, returning the exec and the session it
+	// belongs to. Presenting a used token again revokes the family and ends its session.
+	RotateRefreshToken(hashedToken string, next models.RefreshToken) (models.Exec, int, error)
+	// Revokes the family of the token on logout, and ends the session it belongs to.
+	RevokeRefreshTokenFamily(hashedToken string) error
+}
+
+// Login sessions, see models.Session. Expired sessions are never returned.
+type SessionRepository interface {
+	// Also deletes the expired sessions of the exec.
+	CreateSession(session models.Session) (models.Session, error)
+	GetSession(sessionId int) (models.Session, error)
+	GetExecSessions(execId int) ([]models.Session, error)
+	// Sets last_seen_at, and expires_at unless it is zero.
+	TouchSession(sessionId int, lastSeenAt time.Time, expiresAt time.Time) error
+	// Deletes a session of execId, not found if it belongs to another exec.
+	DeleteSession(execId int, sessionId int) error
+	// Deletes every session of the exec and returns how many there were.
+	DeleteExecSessions(execId int) (int, error)
+}
+
+// Access tokens revoked before they expire, keyed by their jti claim.
+type RevokedTokenRepository interface {
+	RevokeToken(jti string, execId int, expiresAt time.Time) error
+	IsTokenRevoked(jti string) (bool, error)
+	// Revokes a single use token, like the "mfa pending" one. Fails as unauthorized when
+	// it was already used, so only one of concurrent requests succeeds.
+	UseToken(jti string, execId int, expiresAt time.Time) error
+}
+
+// TOTP secrets and hashed recovery codes of execs. A secret stored by SetMFASecret stays
+// pending until EnableMFA, which also stores the recovery codes.
+type MFARepository interface {
+	GetMFA(execId int) (models.ExecMFA, error)
+	SetMFASecret(execId int, secret string) error
+	EnableMFA(execId int, recoveryCodeHashes []string) error
+	DisableMFA(execId int) error
+	// Records the TOTP time step of an accepted code. Steps at or before the last one are
+	// rejected, so a code can't be replayed.
+	UseTOTPStep(execId int, step int64) error
+	// Marks an unused recovery code as used.
+	UseRecoveryCode(execId int, codeHash string) error
+	ReplaceRecoveryCodes(execId int, codeHashes []string) error
+}
+
+// API keys of other services, looked up by the sha256 hex hash of the key they send.
+type APIKeyRepository interface {
+	CreateAPIKey(key models.APIKey) (models.APIKey, error)
+	GetAPIKeys() ([]models.APIKey, error)
+	GetAPIKeyByHash(keyHash string) (models.APIKey, error)
+	// Records when the key was last used.
+	TouchAPIKey(keyId int, usedAt time.Time) error
+	DeleteAPIKey(keyId int) error
+}
+
+// WebAuthn credentials of execs and the challenges of ceremonies in progress.
+type PasskeyRepository interface {
+	// Also deletes the expired challenges.
+	CreatePasskeyChallenge(challenge models.PasskeyChallenge) error
+	// Deletes and returns an unexpired challenge of the ceremony, so each is answered once.
+	ConsumePasskeyChallenge(challenge string, ceremony string) (models.PasskeyChallenge, error)
+	// Fails with a conflict when the credential is already registered.
+	CreatePasskey(passkey models.Passkey) (models.Passkey, error)
+	GetExecPasskeys(execId int) ([]models.Passkey, error)
+	GetPasskeyByCredentialID(credentialId string) (models.Passkey, error)
+	// Stores the sign counter of a login. Only done while the counter is still oldSignCount,
+	// so racing logins can't both move it forward.
+	UsePasskey(passkeyId int, oldSignCount uint32, signCount uint32, usedAt time.Time) error
+	// Deletes a passkey of execId, not found if it belongs to another exec.
+	DeletePasskey(execId int, passkeyId int) error
+}
+
+// Full-text search across teachers and students, ranked by relevance.
+type SearchRepository interface {
+	Search(terms []string, page int, limit int) ([]models.SearchResult, int, error)
+}
+
+// Groups the repositories the API depends on.
+type Repositories struct {
+	Teachers      TeacherRepository
+	Students      StudentRepository
+	Execs         ExecRepository
+	Search        SearchRepository
+	RefreshTokens RefreshTokenRepository
+	RevokedTokens RevokedTokenRepository
+	MFA           MFARepository
+	APIKeys       APIKeyRepository
+	Sessions      SessionRepository
+	Passkeys      PasskeyRepository
+}
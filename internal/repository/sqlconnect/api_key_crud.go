@@ -0,0 +1,113 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = "id, name, role, scopes, prefix, expires_at, last_used_at, created_at, created_by"
+
+func CreateAPIKeyDBHandler(key models.APIKey) (models.APIKey, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.APIKey{}, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	key.CreatedAt = sql.NullString{String: currentTimestamp(), Valid: true}
+	createdBy := sql.NullInt64{Int64: int64(key.CreatedBy), Valid: key.CreatedBy != 0}
+	err = db.QueryRow("INSERT INTO api_keys (name, role, scopes, prefix, key_hash, expires_at, created_at, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id", key.Name, key.Role, pq.Array(key.Scopes), key.Prefix, key.KeyHash, key.ExpiresAt, key.CreatedAt, createdBy).Scan(&key.ID)
+	if err != nil {
+		return models.APIKey{}, dbErrorHandler(err, "Error creating API key.")
+	}
+	return key, nil
+}
+
+func GetAPIKeysDBHandler() ([]models.APIKey, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	rows, err := db.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error retrieving API keys.")
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "Error retrieving API keys.")
+		}
+		keys = append(keys, key)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error retrieving API keys.")
+	}
+	return keys, nil
+}
+
+func GetAPIKeyByHashDBHandler(keyHash string) (models.APIKey, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.APIKey{}, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	key, err := scanAPIKey(db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash=$1", keyHash))
+	if err == sql.ErrNoRows {
+		return models.APIKey{}, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Invalid API key.")
+	} else if err != nil {
+		return models.APIKey{}, utils.ErrorHandler(err, "Error retrieving API key.")
+	}
+	key.KeyHash = keyHash
+	return key, nil
+}
+
+func TouchAPIKeyDBHandler(keyId int, usedAt time.Time) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	_, err = db.Exec("UPDATE api_keys SET last_used_at=$1 WHERE id=$2", usedAt.UTC().Format(time.RFC3339Nano), keyId)
+	if err != nil {
+		return utils.ErrorHandler(err, "Error updating API key.")
+	}
+	return nil
+}
+
+func DeleteAPIKeyDBHandler(keyId int) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	res, err := db.Exec("DELETE FROM api_keys WHERE id=$1", keyId)
+	if err != nil {
+		return utils.ErrorHandler(err, "Error deleting API key.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error deleting API key.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("API key %d not found.", keyId))
+	}
+	return nil
+}
+
+// Scans a row selected with apiKeyColumns.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (models.APIKey, error) {
+	var key models.APIKey
+	var createdBy sql.NullInt64
+	err := row.Scan(&key.ID, &key.Name, &key.Role, pq.Array(&key.Scopes), &key.Prefix, &key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt, &createdBy)
+	key.CreatedBy = int(createdBy.Int64)
+	return key, err
+}
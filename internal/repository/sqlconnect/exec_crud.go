@@ -1,10 +1,7 @@
 package sqlconnect
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
@@ -13,17 +10,21 @@ import (
 	"time"
 
 	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository"
 	"github.com/brickster241/rest-go/pkg/utils"
 )
 
-func GetExecsDBHandler(query string, args []interface{}) ([]models.Exec, int, error) {
-	db, err := ConnectDB()
+func GetExecsDBHandler(opts repository.ListOptions) ([]models.Exec, int, error) {
+	db, err := getDB()
 	if err != nil {
 		return []models.Exec{}, 0, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
-	rows, err := db.Query(query, args...)
+	where, args := buildWhereClause(opts)
+	cursorClause, cursorArgs := buildCursorClause(opts, len(args))
+	query := "SELECT id, first_name, last_name, email, username, user_created_at, inactive_status, role, failed_login_attempts, locked_until, invite_token_expires FROM execs WHERE 1=1" + where + cursorClause + buildPageClause(opts)
+
+	rows, err := db.Query(query, append(args, cursorArgs...)...)
 	if err != nil {
 		return []models.Exec{}, 0, utils.ErrorHandler(err, "Error fetching Execs.")
 	}
@@ -34,33 +35,37 @@ func GetExecsDBHandler(query string, args []interface{}) ([]models.Exec, int, er
 	execList := make([]models.Exec, 0)
 	for rows.Next() {
 		var exec models.Exec
-		err = rows.Scan(&exec.ID, &exec.FirstName, &exec.LastName, &exec.Email, &exec.Username, &exec.UserCreatedAt, &exec.InactiveStatus, &exec.Role)
+		err = rows.Scan(&exec.ID, &exec.FirstName, &exec.LastName, &exec.Email, &exec.Username, &exec.UserCreatedAt, &exec.InactiveStatus, &exec.Role, &exec.FailedLoginAttempts, &exec.LockedUntil, &exec.InviteTokenExpires)
 		if err != nil {
 			return []models.Exec{}, 0, utils.ErrorHandler(err, "Error fetching Execs.")
 		}
 		execList = append(execList, exec)
 	}
 
+	err = rows.Err()
+	if err != nil {
+		return []models.Exec{}, 0, utils.ErrorHandler(err, "Error fetching Execs.")
+	}
+
+	// Count with the same filters, so the total matches the pages.
 	var totalExecs int
-	err = db.QueryRow("SELECT COUNT(*) FROM execs").Scan(&totalExecs)
+	err = db.QueryRow("SELECT COUNT(*) FROM execs WHERE 1=1"+where, args...).Scan(&totalExecs)
 	if err != nil {
-		utils.ErrorHandler(err, "")
-		return execList, 0, nil
+		return []models.Exec{}, 0, utils.ErrorHandler(err, "Error counting Execs.")
 	}
 	return execList, totalExecs, nil
 }
 
 func GetOneExecDBHandler(execId int) (models.Exec, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return models.Exec{}, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	var exec models.Exec
-	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, username, user_created_at, inactive_status, role FROM execs WHERE id = %d", execId)).Scan(&exec.ID, &exec.FirstName, &exec.LastName, &exec.Email, &exec.Username, &exec.UserCreatedAt, &exec.InactiveStatus, &exec.Role)
+	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, username, user_created_at, inactive_status, role, failed_login_attempts, last_failed_login_at, locked_until, invite_token_expires FROM execs WHERE id = %d", execId)).Scan(&exec.ID, &exec.FirstName, &exec.LastName, &exec.Email, &exec.Username, &exec.UserCreatedAt, &exec.InactiveStatus, &exec.Role, &exec.FailedLoginAttempts, &exec.LastFailedLoginAt, &exec.LockedUntil, &exec.InviteTokenExpires)
 	if err == sql.ErrNoRows {
-		return models.Exec{}, utils.ErrorHandler(err, fmt.Sprintf("Error fetching Exec %d.", execId))
+		return models.Exec{}, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
 	} else if err != nil {
 		return models.Exec{}, utils.ErrorHandler(err, fmt.Sprintf("Error fetching Exec %d.", execId))
 	}
@@ -68,11 +73,10 @@ func GetOneExecDBHandler(execId int) (models.Exec, error) {
 }
 
 func PostExecsDBHandler(newExecs []models.Exec) ([]models.Exec, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return nil, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	// Prepare Query
 	tx, err := db.Begin()
@@ -90,17 +94,22 @@ func PostExecsDBHandler(newExecs []models.Exec) ([]models.Exec, error) {
 	addedExecs := make([]models.Exec, len(newExecs))
 	for i, newExec := range newExecs {
 
-		hashPassword, err := utils.HashPassword(newExec.Password)
-		if err != nil {
-			return nil, err
+		// Invited execs have no password until they accept the invitation.
+		if newExec.Password != "" || !newExec.InviteToken.Valid {
+			hashPassword, err := utils.HashPassword(newExec.Password)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			newExec.Password = hashPassword
 		}
-		newExec.Password = hashPassword
+		newExec.UserCreatedAt = sql.NullString{String: currentTimestamp(), Valid: true}
 
 		values := getStructValues(newExec)
 		_, err = stmt.Exec(values...)
 		if err != nil {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error Adding execs.")
+			return nil, dbErrorHandler(err, "Error Adding execs.")
 		}
 		addedExecs[i] = newExec
 	}
@@ -113,16 +122,15 @@ func PostExecsDBHandler(newExecs []models.Exec) ([]models.Exec, error) {
 }
 
 func PatchOneExecDBHandler(execId int, updates map[string]interface{}) (models.Exec, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return models.Exec{}, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	var existingExec models.Exec
-	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, username FROM execs WHERE id = %d", execId)).Scan(&existingExec.ID, &existingExec.FirstName, &existingExec.LastName, &existingExec.Email, &existingExec.Username)
+	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, username, inactive_status, role FROM execs WHERE id = %d", execId)).Scan(&existingExec.ID, &existingExec.FirstName, &existingExec.LastName, &existingExec.Email, &existingExec.Username, &existingExec.InactiveStatus, &existingExec.Role)
 	if err == sql.ErrNoRows {
-		return models.Exec{}, utils.ErrorHandler(err, fmt.Sprintf("Error updating Exec %d.", execId))
+		return models.Exec{}, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
 	} else if err != nil {
 		return models.Exec{}, utils.ErrorHandler(err, fmt.Sprintf("Error updating Exec %d.", execId))
 	}
@@ -138,24 +146,27 @@ func PatchOneExecDBHandler(execId int, updates map[string]interface{}) (models.E
 
 			// Check whether such key exists in fields and set its value to v
 			if json_field == k+",omitempty" && execVal.Field(i).CanSet() {
+				if v == nil || !reflect.ValueOf(v).Type().ConvertibleTo(execVal.Field(i).Type()) {
+					return models.Exec{}, utils.TypedErrorHandler(fmt.Errorf("cannot convert %T", v), utils.ErrValidation, fmt.Sprintf("Invalid value for field %s.", k))
+				}
 				execVal.Field(i).Set(reflect.ValueOf(v).Convert(execVal.Field(i).Type()))
 			}
 		}
 	}
 
-	_, err = db.Exec("UPDATE execs SET first_name=$1, last_name=$2, email=$3, username=$4 WHERE id=$5", existingExec.FirstName, existingExec.LastName, existingExec.Email, existingExec.Username, existingExec.ID)
+	// role and inactive_status are only sent by admins, the handlers reject them for everyone else.
+	_, err = db.Exec("UPDATE execs SET first_name=$1, last_name=$2, email=$3, username=$4, inactive_status=$5, role=$6 WHERE id=$7", existingExec.FirstName, existingExec.LastName, existingExec.Email, existingExec.Username, existingExec.InactiveStatus, existingExec.Role, existingExec.ID)
 	if err != nil {
-		return models.Exec{}, utils.ErrorHandler(err, fmt.Sprintf("Error updating Exec %d.", execId))
+		return models.Exec{}, dbErrorHandler(err, fmt.Sprintf("Error updating Exec %d.", execId))
 	}
 	return existingExec, nil
 }
 
 func PatchExecsDBHandler(updates []map[string]interface{}) ([]models.Exec, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return nil, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -167,20 +178,20 @@ func PatchExecsDBHandler(updates []map[string]interface{}) ([]models.Exec, error
 		execIdStr, ok := update["id"].(string)
 		if !ok {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Execs.")
+			return nil, utils.TypedErrorHandler(errors.New("id should be a string"), utils.ErrValidation, "Invalid Exec ID.")
 		}
 
 		execId, err := strconv.Atoi(execIdStr)
 		if err != nil {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Execs.")
+			return nil, utils.TypedErrorHandler(err, utils.ErrValidation, "Invalid Exec ID.")
 		}
 
 		var existingExec models.Exec
-		err = tx.QueryRow("SELECT id, first_name, last_name, email, username FROM execs WHERE id = $1", execId).Scan(&existingExec.ID, &existingExec.FirstName, &existingExec.LastName, &existingExec.Email, &existingExec.Username)
+		err = tx.QueryRow("SELECT id, first_name, last_name, email, username, inactive_status, role FROM execs WHERE id = $1", execId).Scan(&existingExec.ID, &existingExec.FirstName, &existingExec.LastName, &existingExec.Email, &existingExec.Username, &existingExec.InactiveStatus, &existingExec.Role)
 		if err == sql.ErrNoRows {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Execs.")
+			return nil, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
 		} else if err != nil {
 			tx.Rollback()
 			return nil, utils.ErrorHandler(err, "Error updating Execs.")
@@ -200,21 +211,21 @@ func PatchExecsDBHandler(updates []map[string]interface{}) ([]models.Exec, error
 
 				// Check whether such key exists in fields and set its value to v
 				if json_field == k+",omitempty" && execVal.Field(i).CanSet() {
-					if reflect.ValueOf(v).Type().ConvertibleTo(execVal.Field(i).Type()) {
+					if v != nil && reflect.ValueOf(v).Type().ConvertibleTo(execVal.Field(i).Type()) {
 						execVal.Field(i).Set(reflect.ValueOf(v).Convert(execVal.Field(i).Type()))
 					} else {
 						tx.Rollback()
-						return nil, utils.ErrorHandler(err, "Error updating Execs.")
+						return nil, utils.TypedErrorHandler(fmt.Errorf("cannot convert %T", v), utils.ErrValidation, fmt.Sprintf("Invalid value for field %s.", k))
 					}
 					break
 				}
 			}
 		}
 
-		_, err = tx.Exec("UPDATE execs SET first_name=$1, last_name=$2, email=$3, username=$4 WHERE id=$5", existingExec.FirstName, existingExec.LastName, existingExec.Email, existingExec.Username, existingExec.ID)
+		_, err = tx.Exec("UPDATE execs SET first_name=$1, last_name=$2, email=$3, username=$4, inactive_status=$5, role=$6 WHERE id=$7", existingExec.FirstName, existingExec.LastName, existingExec.Email, existingExec.Username, existingExec.InactiveStatus, existingExec.Role, existingExec.ID)
 		if err != nil {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Execs.")
+			return nil, dbErrorHandler(err, "Error updating Execs.")
 		}
 		existingExecs = append(existingExecs, existingExec)
 	}
@@ -227,12 +238,11 @@ func PatchExecsDBHandler(updates []map[string]interface{}) ([]models.Exec, error
 }
 
 func DeleteOneExecDBHandler(execId int) error {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		// log.Println("Error connecting DB :", err)
 		return utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	// Perform the delete operation
 	res, err := db.Exec("DELETE FROM execs WHERE id=$1", execId)
@@ -247,59 +257,81 @@ func DeleteOneExecDBHandler(execId int) error {
 
 	// Operation was successful, but no rows affected i.e. invalid ID.
 	if rowsAffected == 0 {
-		return utils.ErrorHandler(err, fmt.Sprintf("Error deleting Exec %d.", execId))
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
 	}
 	return nil
 }
 
 func LoginExecDBHandler(req models.Exec) (models.Exec, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
 	}
 
-	defer db.Close()
-
 	exec := models.Exec{}
-	err = db.QueryRow("SELECT id, first_name, last_name, email, username, password, inactive_status, role from execs WHERE username=$1", req.Username).Scan(&exec.ID, &exec.FirstName, &exec.LastName, &exec.Email, &exec.Username, &exec.Password, &exec.InactiveStatus, &exec.Role)
+	err = db.QueryRow("SELECT id, first_name, last_name, email, username, password, inactive_status, role, failed_login_attempts, last_failed_login_at, locked_until from execs WHERE username=$1", req.Username).Scan(&exec.ID, &exec.FirstName, &exec.LastName, &exec.Email, &exec.Username, &exec.Password, &exec.InactiveStatus, &exec.Role, &exec.FailedLoginAttempts, &exec.LastFailedLoginAt, &exec.LockedUntil)
 	if err == sql.ErrNoRows {
-		return models.Exec{}, utils.ErrorHandler(err, "Incorrect Username / Password.")
+		return models.Exec{}, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Incorrect Username / Password.")
 	}
 	if err != nil {
 		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
 	}
 
 	if exec.InactiveStatus {
-		return models.Exec{}, utils.ErrorHandler(errors.New("account is inactive"), "Account is inactive.")
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("account is inactive"), utils.ErrForbidden, "Account is inactive.")
 	}
 	return exec, nil
 }
 
 func UpdateExecPasswordDBHandler(execId int, req models.UpdatePasswordRequest) (string, string, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return "", "", utils.ErrorHandler(err, "Internal Server Error.")
 	}
-
-	defer db.Close()
 	var execName string
 	var execPwd string
 	var execRole string
 
-	err = db.QueryRow("SELECT username, password, role FROM execs WHERE id=$1", execId).Scan(&execName, &execPwd, &execRole)
+	// The password, its history and the sessions it ends change together, or not at all.
+	tx, err := db.Begin()
+	if err != nil {
+		return "", "", utils.ErrorHandler(err, "Failed to Update Password.")
+	}
+
+	err = tx.QueryRow("SELECT username, password, role FROM execs WHERE id=$1 FOR UPDATE", execId).Scan(&execName, &execPwd, &execRole)
 	if err != nil {
-		return "", "", utils.ErrorHandler(err, "User Not Found.")
+		tx.Rollback()
+		return "", "", dbErrorHandler(err, "User Not Found.")
 	}
 	err = utils.VerifyPassword(execPwd, req.CurrentPassword)
 	if err != nil {
+		tx.Rollback()
 		return "", "", err
 	}
 
 	hashedPassword, err := utils.HashPassword(req.NewPassword)
 	if err != nil {
+		tx.Rollback()
 		return "", "", err
 	}
-	_, err = db.Exec("UPDATE execs SET password=$1, password_changed_at=$2 WHERE id=$3", hashedPassword, time.Now(), execId)
+	// Stored in UTC, JWT_MW compares it with the iat of access tokens.
+	_, err = tx.Exec("UPDATE execs SET password=$1, password_changed_at=$2 WHERE id=$3", hashedPassword, time.Now().UTC(), execId)
+	if err != nil {
+		tx.Rollback()
+		return "", "", dbErrorHandler(err, "Failed to Update Password.")
+	}
+	err = recordPasswordHistory(tx, execId, execPwd)
+	if err != nil {
+		tx.Rollback()
+		return "", "", utils.ErrorHandler(err, "Failed to Update Password.")
+	}
+	_, err = endExecSessions(tx, execId)
+	if err != nil {
+		tx.Rollback()
+		return "", "", utils.ErrorHandler(err, "Failed to Update Password.")
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return "", "", utils.ErrorHandler(err, "Failed to Update Password.")
 	}
@@ -307,16 +339,15 @@ func UpdateExecPasswordDBHandler(execId int, req models.UpdatePasswordRequest) (
 }
 
 func ForgotExecPasswordDBHandler(execEmail string) (time.Duration, string, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return 0, "", utils.ErrorHandler(err, "Internal Server Error.")
 	}
-	defer db.Close()
 
 	var exec models.Exec
 	err = db.QueryRow("SELECT id FROM execs WHERE email=$1", execEmail).Scan(&exec.ID)
 	if err != nil {
-		return 0, "", utils.ErrorHandler(err, "User Not Found.")
+		return 0, "", dbErrorHandler(err, "User Not Found.")
 	}
 
 	duration, err := strconv.Atoi(os.Getenv("RESET_TOKEN_EXP_DURATION"))
@@ -325,39 +356,316 @@ func ForgotExecPasswordDBHandler(execEmail string) (time.Duration, string, error
 	}
 	mins := time.Duration(duration)
 	expiry := time.Now().Add(mins * time.Minute)
-	tokenBytes := make([]byte, 32)
-	_, err = rand.Read(tokenBytes)
+	token, hashedTokenString, err := utils.GenerateHashedToken()
 	if err != nil {
 		return 0, "", utils.ErrorHandler(err, "Failed to send Password reset email.")
 	}
 
-	token := hex.EncodeToString(tokenBytes)
-	hashedToken := sha256.Sum256(tokenBytes)
-	hashedTokenString := hex.EncodeToString(hashedToken[:])
 	_, err = db.Exec("UPDATE execs SET password_reset_token=$1, password_token_expires=$2 WHERE id=$3", hashedTokenString, expiry, exec.ID)
 	if err != nil {
-		return 0, "", utils.ErrorHandler(err, "Failed to send Password reset email.")
+		return 0, "", dbErrorHandler(err, "Failed to send Password reset email.")
 	}
 	return mins, token, nil
 }
 
 func ResetPasswordDBHandler(hashedTokenString string, hashedPwd string) error {
-	db, err := ConnectDB()
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	var exec models.Exec
+
+	// Same as UpdateExecPasswordDBHandler, every change is made in one transaction.
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	err = tx.QueryRow("SELECT id, email, password FROM execs WHERE password_reset_token=$1 and password_token_expires > $2 FOR UPDATE", hashedTokenString, time.Now()).Scan(&exec.ID, &exec.Email, &exec.Password)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Invalid / Expired Reset Code.")
+	} else if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	// Proving access to the email also lifts a lockout.
+	_, err = tx.Exec("UPDATE execs SET password=$1, password_reset_token=NULL, password_token_expires=NULL, password_changed_at=$2, failed_login_attempts=0, last_failed_login_at=NULL, locked_until=NULL WHERE id=$3", hashedPwd, time.Now().UTC(), exec.ID)
+	if err != nil {
+		tx.Rollback()
+		return dbErrorHandler(err, "Internal Server Error.")
+	}
+	err = recordPasswordHistory(tx, exec.ID, exec.Password)
 	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	_, err = endExecSessions(tx, exec.ID)
+	if err != nil {
+		tx.Rollback()
 		return utils.ErrorHandler(err, "Internal Server Error.")
 	}
-	defer db.Close()
+
+	err = tx.Commit()
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	return nil
+}
+
+func GetExecAuthStateDBHandler(execId int) (models.Exec, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
 
 	var exec models.Exec
+	err = db.QueryRow("SELECT id, inactive_status, role, password_changed_at FROM execs WHERE id=$1", execId).Scan(&exec.ID, &exec.InactiveStatus, &exec.Role, &exec.PasswordChangedAt)
+	if err == sql.ErrNoRows {
+		return models.Exec{}, utils.TypedErrorHandler(err, utils.ErrNotFound, "User Not Found.")
+	} else if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	return exec, nil
+}
 
-	err = db.QueryRow("SELECT id, email FROM execs WHERE password_reset_token=$1 and password_token_expires > $2", hashedTokenString, time.Now()).Scan(&exec.ID, &exec.Email)
+func RecordFailedLoginDBHandler(execId int, at time.Time, since time.Time) (int, error) {
+	db, err := getDB()
 	if err != nil {
-		return utils.ErrorHandler(err, "Invalid / Expired Reset Code.")
+		return 0, utils.ErrorHandler(err, "Internal Server Error.")
 	}
 
-	_, err = db.Exec("UPDATE execs SET password=$1, password_reset_token=NULL, password_token_expires=NULL, password_changed_at=$2 WHERE id=$3", hashedPwd, time.Now(), exec.ID)
+	// Incremented in the UPDATE, so concurrent failures are all counted.
+	var failures int
+	err = db.QueryRow("UPDATE execs SET failed_login_attempts=CASE WHEN last_failed_login_at >= $2 THEN failed_login_attempts+1 ELSE 1 END, last_failed_login_at=$1 WHERE id=$3 RETURNING failed_login_attempts", at.UTC(), since.UTC(), execId).Scan(&failures)
+	if err == sql.ErrNoRows {
+		return 0, utils.TypedErrorHandler(err, utils.ErrNotFound, "User Not Found.")
+	} else if err != nil {
+		return 0, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	return failures, nil
+}
+
+func LockExecDBHandler(execId int, until time.Time) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	_, err = db.Exec("UPDATE execs SET locked_until=$1 WHERE id=$2", until.UTC(), execId)
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	return nil
+}
+
+func ResetFailedLoginsDBHandler(execId int) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	res, err := db.Exec("UPDATE execs SET failed_login_attempts=0, last_failed_login_at=NULL, locked_until=NULL WHERE id=$1", execId)
 	if err != nil {
 		return utils.ErrorHandler(err, "Internal Server Error.")
 	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+func RehashPasswordDBHandler(execId int, oldHash string, newHash string) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	// password_changed_at stays, so tokens issued before the rehash remain valid.
+	_, err = db.Exec("UPDATE execs SET password=$1 WHERE id=$2 AND password=$3", newHash, execId, oldHash)
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	return nil
+}
+
+func GetPasswordHistoryDBHandler(execId int, limit int) ([]string, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	var currentHash string
+	err = db.QueryRow("SELECT password FROM execs WHERE id=$1", execId).Scan(&currentHash)
+	if err != nil {
+		return nil, dbErrorHandler(err, "User Not Found.")
+	}
+	hashes := []string{currentHash}
+	if limit <= 1 {
+		return hashes, nil
+	}
+
+	rows, err := db.Query("SELECT password_hash FROM password_history WHERE exec_id=$1 ORDER BY id DESC LIMIT $2", execId, limit-1)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash string
+		err = rows.Scan(&hash)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "Internal Server Error.")
+		}
+		hashes = append(hashes, hash)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	return hashes, nil
+}
+
+func GetExecByResetTokenDBHandler(hashedTokenString string) (models.Exec, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	var exec models.Exec
+	err = db.QueryRow("SELECT id, username, email FROM execs WHERE password_reset_token=$1 and password_token_expires > $2", hashedTokenString, time.Now()).Scan(&exec.ID, &exec.Username, &exec.Email)
+	if err == sql.ErrNoRows {
+		return models.Exec{}, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Invalid / Expired Reset Code.")
+	} else if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	return exec, nil
+}
+
+func ReissueExecInviteDBHandler(execId int, hashedTokenString string, expiresAt time.Time) (models.Exec, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	var exec models.Exec
+	err = db.QueryRow("SELECT id, first_name, username, email, password FROM execs WHERE id=$1", execId).Scan(&exec.ID, &exec.FirstName, &exec.Username, &exec.Email, &exec.Password)
+	if err == sql.ErrNoRows {
+		return models.Exec{}, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	} else if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	if exec.Password != "" {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("invitation already accepted"), utils.ErrConflict, "Exec has already set a password.")
+	}
+
+	exec.InviteTokenExpires = sql.NullString{String: expiresAt.UTC().Format(time.RFC3339Nano), Valid: true}
+	_, err = db.Exec("UPDATE execs SET invite_token=$1, invite_token_expires=$2 WHERE id=$3 AND password=''", hashedTokenString, expiresAt.UTC(), execId)
+	if err != nil {
+		return models.Exec{}, dbErrorHandler(err, "Failed to send the invitation.")
+	}
+	return exec, nil
+}
+
+func GetExecByInviteTokenDBHandler(hashedTokenString string) (models.Exec, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	// Setting a password another way, e.g. through a reset, also ends the invitation.
+	var exec models.Exec
+	err = db.QueryRow("SELECT id, username, email FROM execs WHERE invite_token=$1 AND invite_token_expires > $2 AND password=''", hashedTokenString, time.Now().UTC()).Scan(&exec.ID, &exec.Username, &exec.Email)
+	if err == sql.ErrNoRows {
+		return models.Exec{}, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Invalid / Expired Invitation Code.")
+	} else if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	return exec, nil
+}
+
+func AcceptExecInviteDBHandler(hashedTokenString string, hashedPwd string) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	// A single UPDATE, so two requests with the same code can't both set a password.
+	res, err := db.Exec("UPDATE execs SET password=$1, password_changed_at=$2, invite_token=NULL, invite_token_expires=NULL WHERE invite_token=$3 AND invite_token_expires > $2 AND password=''", hashedPwd, time.Now().UTC(), hashedTokenString)
+	if err != nil {
+		return dbErrorHandler(err, "Internal Server Error.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "Internal Server Error.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrUnauthorized, "Invalid / Expired Invitation Code.")
+	}
+	return nil
+}
+
+func CreateMagicLinkDBHandler(execEmail string, hashedTokenString string, expiresAt time.Time) (models.Exec, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	var exec models.Exec
+	err = db.QueryRow("SELECT id, first_name, username, email, inactive_status FROM execs WHERE lower(email)=lower($1)", execEmail).Scan(&exec.ID, &exec.FirstName, &exec.Username, &exec.Email, &exec.InactiveStatus)
+	if err != nil {
+		return models.Exec{}, dbErrorHandler(err, "User Not Found.")
+	}
+	if exec.InactiveStatus {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("account is inactive"), utils.ErrForbidden, "Account is inactive.")
+	}
+
+	_, err = db.Exec("UPDATE execs SET magic_link_token=$1, magic_link_expires=$2 WHERE id=$3", hashedTokenString, expiresAt.UTC(), exec.ID)
+	if err != nil {
+		return models.Exec{}, dbErrorHandler(err, "Failed to send the login link.")
+	}
+	return exec, nil
+}
+
+func ConsumeMagicLinkDBHandler(hashedTokenString string) (models.Exec, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	// Cleared in the same statement, so a link can't log in twice. Locked accounts and invited
+	// execs who haven't set a password yet can't use one.
+	var exec models.Exec
+	err = db.QueryRow("UPDATE execs SET magic_link_token=NULL, magic_link_expires=NULL WHERE magic_link_token=$1 AND magic_link_expires > $2 AND (locked_until IS NULL OR locked_until < $2) AND password <> '' RETURNING id, first_name, last_name, email, username, inactive_status, role", hashedTokenString, time.Now().UTC()).Scan(&exec.ID, &exec.FirstName, &exec.LastName, &exec.Email, &exec.Username, &exec.InactiveStatus, &exec.Role)
+	if err == sql.ErrNoRows {
+		return models.Exec{}, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Invalid / Expired Login Link.")
+	} else if err != nil {
+		return models.Exec{}, utils.ErrorHandler(err, "Internal Server Error.")
+	}
+
+	// Same check as LoginExecDBHandler, the account may have been deactivated since the link was sent.
+	if exec.InactiveStatus {
+		return models.Exec{}, utils.TypedErrorHandler(errors.New("account is inactive"), utils.ErrForbidden, "Account is inactive.")
+	}
+	return exec, nil
+}
+
+// Keeps the replaced hash of a password change, dropping the rows beyond the history size.
+func recordPasswordHistory(db sqlExecer, execId int, oldHash string) error {
+	// Execs who set their first password through a reset had no previous one.
+	if oldHash == "" {
+		return nil
+	}
+	_, err := db.Exec("INSERT INTO password_history (exec_id, password_hash) VALUES ($1, $2)", execId, oldHash)
+	if err != nil {
+		return err
+	}
+	keep := max(utils.CurrentPasswordPolicy().History-1, 0)
+	_, err = db.Exec("DELETE FROM password_history WHERE exec_id=$1 AND id NOT IN (SELECT id FROM password_history WHERE exec_id=$1 ORDER BY id DESC LIMIT $2)", execId, keep)
+	return err
+}
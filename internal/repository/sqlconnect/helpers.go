@@ -1,11 +1,23 @@
 package sqlconnect
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/repository"
+	"github.com/brickster241/rest-go/pkg/utils"
+	"github.com/lib/pq"
 )
 
+// Statements a helper can run on either the pool or a transaction, *sql.DB and *sql.Tx.
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
 func generateInsertQuery(tableName string, model interface{}) string {
 	modelType := reflect.TypeOf(model)
 	var columns, placeholders string
@@ -27,6 +39,11 @@ func generateInsertQuery(tableName string, model interface{}) string {
 	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", tableName, columns, placeholders)
 }
 
+// Creation timestamps are written in UTC by the API, the same format the filters use.
+func currentTimestamp() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
 // Get All Struct Values in a slice using reflect
 func getStructValues(model interface{}) []interface{} {
 	modelValue := reflect.ValueOf(model)
@@ -39,4 +56,127 @@ func getStructValues(model interface{}) []interface{} {
 		}
 	}
 	return values
-}
\ No newline at end of file
+}
+// SQL comparison for each filter operator, in and like are handled separately.
+var sqlOperators = map[string]string{
+	repository.OpEq:  "=",
+	repository.OpNe:  "<>",
+	repository.OpGt:  ">",
+	repository.OpGte: ">=",
+	repository.OpLt:  "<",
+	repository.OpLte: "<=",
+}
+
+// Builds the " AND ..." filter clause for a "WHERE 1=1" query. The same clause is used
+// for the page and the total count, so the count matches the filters.
+// Field names and operators are whitelisted by the handlers, values are always passed as args.
+func buildWhereClause(opts repository.ListOptions) (string, []interface{}) {
+	var where string
+	var args []interface{}
+	for _, filter := range opts.Filters {
+		var condition string
+		switch filter.Operator {
+		case repository.OpIn:
+			placeholders := make([]string, len(filter.Values))
+			for i, value := range filter.Values {
+				args = append(args, value)
+				placeholders[i] = fmt.Sprintf("$%d", len(args))
+			}
+			condition = fmt.Sprintf("%s IN (%s)", filter.Field, strings.Join(placeholders, ", "))
+		case repository.OpLike:
+			args = append(args, filter.Values[0])
+			condition = fmt.Sprintf("%s ILIKE $%d", filter.Field, len(args))
+		default:
+			args = append(args, filter.Values[0])
+			condition = fmt.Sprintf("%s %s $%d", filter.Field, sqlOperators[filter.Operator], len(args))
+		}
+
+		if filter.Negate {
+			condition = "NOT (" + condition + ")"
+		}
+		where += " AND " + condition
+	}
+
+	if len(opts.Search) > 0 {
+		args = append(args, buildTSQuery(opts.Search))
+		where += fmt.Sprintf(" AND search_vector @@ to_tsquery('simple', $%d)", len(args))
+	}
+	return where, args
+}
+
+// Builds a tsquery matching every term as a prefix, e.g. "jo:* & 9a:*". Terms only hold letters
+// and digits (see utils.SearchTerms), so they can't inject tsquery operators.
+func buildTSQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+// Builds the keyset condition selecting the rows after opts.After, following the sort order
+// and then id. Placeholders are numbered after the argCount args already in the query.
+func buildCursorClause(opts repository.ListOptions, argCount int) (string, []interface{}) {
+	if opts.After == nil {
+		return "", nil
+	}
+
+	// (a > $1) OR (a = $1 AND b < $2) OR (a = $1 AND b = $2 AND id > $3), for a:asc, b:desc
+	var conditions []string
+	var args []interface{}
+	for i := 0; i <= len(opts.Sort); i++ {
+		var parts []string
+		for j := 0; j < i; j++ {
+			args = append(args, opts.After.Values[j])
+			parts = append(parts, fmt.Sprintf("%s = $%d", opts.Sort[j].Field, argCount+len(args)))
+		}
+		if i < len(opts.Sort) {
+			operator := ">"
+			if opts.Sort[i].Order == "desc" {
+				operator = "<"
+			}
+			args = append(args, opts.After.Values[i])
+			parts = append(parts, fmt.Sprintf("%s %s $%d", opts.Sort[i].Field, operator, argCount+len(args)))
+		} else {
+			args = append(args, opts.After.ID)
+			parts = append(parts, fmt.Sprintf("id > $%d", argCount+len(args)))
+		}
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	return " AND (" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// Builds the ORDER BY, LIMIT and OFFSET part of a list query.
+// Rows are always ordered by id last, so pages are stable when sort values repeat.
+func buildPageClause(opts repository.ListOptions) string {
+	clause := " ORDER BY"
+
+	// To ensure to incorporate multiple sorting values
+	for _, sort := range opts.Sort {
+		clause += fmt.Sprintf(" %s %s,", sort.Field, sort.Order)
+	}
+	clause += " id"
+
+	offset := (opts.Page - 1) * opts.Limit
+	clause += fmt.Sprintf(" LIMIT %d OFFSET %d", opts.Limit, offset)
+	return clause
+}
+
+// Classifies a DB error : missing rows are NotFound, unique / foreign key violations are Conflict,
+// and constraint or type violations are Validation. Anything else stays an internal error.
+func dbErrorHandler(err error, msg string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.TypedErrorHandler(err, utils.ErrNotFound, msg)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "unique_violation", "foreign_key_violation":
+			return utils.TypedErrorHandler(err, utils.ErrConflict, msg)
+		case "not_null_violation", "check_violation", "string_data_right_truncation", "invalid_text_representation":
+			return utils.TypedErrorHandler(err, utils.ErrValidation, msg)
+		}
+	}
+	return utils.ErrorHandler(err, msg)
+}
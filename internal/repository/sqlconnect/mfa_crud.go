@@ -0,0 +1,205 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+func GetMFADBHandler(execId int) (models.ExecMFA, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.ExecMFA{}, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	var mfa models.ExecMFA
+	var secret sql.NullString
+	err = db.QueryRow("SELECT id, mfa_secret, mfa_enabled, mfa_last_step, (SELECT COUNT(*) FROM exec_recovery_codes WHERE exec_id=execs.id AND used_at IS NULL) FROM execs WHERE id=$1", execId).Scan(&mfa.ExecID, &secret, &mfa.Enabled, &mfa.LastStep, &mfa.RecoveryCodesLeft)
+	if err == sql.ErrNoRows {
+		return models.ExecMFA{}, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	} else if err != nil {
+		return models.ExecMFA{}, utils.ErrorHandler(err, "Error retrieving MFA settings.")
+	}
+	mfa.Secret = secret.String
+	return mfa, nil
+}
+
+func SetMFASecretDBHandler(execId int, secret string) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	// An enabled secret is never replaced here, MFA has to be disabled first.
+	res, err := db.Exec("UPDATE execs SET mfa_secret=$1, mfa_last_step=0 WHERE id=$2 AND mfa_enabled=FALSE", secret, execId)
+	if err != nil {
+		return utils.ErrorHandler(err, "Error enrolling MFA.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error enrolling MFA.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(errors.New("mfa already enabled"), utils.ErrConflict, "MFA is already enabled.")
+	}
+	return nil
+}
+
+func EnableMFADBHandler(execId int, recoveryCodeHashes []string) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error enabling MFA.")
+	}
+
+	res, err := tx.Exec("UPDATE execs SET mfa_enabled=TRUE WHERE id=$1 AND mfa_secret IS NOT NULL", execId)
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Error enabling MFA.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Error enabling MFA.")
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return utils.TypedErrorHandler(errors.New("no mfa secret"), utils.ErrValidation, "MFA enrollment not started.")
+	}
+
+	err = replaceRecoveryCodes(tx, execId, recoveryCodeHashes)
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Error enabling MFA.")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error enabling MFA.")
+	}
+	return nil
+}
+
+func DisableMFADBHandler(execId int) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error disabling MFA.")
+	}
+
+	res, err := tx.Exec("UPDATE execs SET mfa_secret=NULL, mfa_enabled=FALSE, mfa_last_step=0 WHERE id=$1", execId)
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Error disabling MFA.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Error disabling MFA.")
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	}
+
+	_, err = tx.Exec("DELETE FROM exec_recovery_codes WHERE exec_id=$1", execId)
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Error disabling MFA.")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error disabling MFA.")
+	}
+	return nil
+}
+
+func UseTOTPStepDBHandler(execId int, step int64) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	// The condition on mfa_last_step makes concurrent uses of the same code race safely.
+	res, err := db.Exec("UPDATE execs SET mfa_last_step=$1 WHERE id=$2 AND mfa_last_step < $1", step, execId)
+	if err != nil {
+		return utils.ErrorHandler(err, "Error verifying MFA code.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error verifying MFA code.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(errors.New("totp step already used"), utils.ErrUnauthorized, "MFA code already used, wait for the next one.")
+	}
+	return nil
+}
+
+func UseRecoveryCodeDBHandler(execId int, codeHash string) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	res, err := db.Exec("UPDATE exec_recovery_codes SET used_at=$1 WHERE exec_id=$2 AND code_hash=$3 AND used_at IS NULL", time.Now(), execId, codeHash)
+	if err != nil {
+		return utils.ErrorHandler(err, "Error verifying recovery code.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error verifying recovery code.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(errors.New("recovery code not found"), utils.ErrUnauthorized, "Invalid recovery code.")
+	}
+	return nil
+}
+
+func ReplaceRecoveryCodesDBHandler(execId int, codeHashes []string) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error storing recovery codes.")
+	}
+	err = replaceRecoveryCodes(tx, execId, codeHashes)
+	if err != nil {
+		tx.Rollback()
+		return dbErrorHandler(err, "Error storing recovery codes.")
+	}
+	err = tx.Commit()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error storing recovery codes.")
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, execId int, codeHashes []string) error {
+	_, err := tx.Exec("DELETE FROM exec_recovery_codes WHERE exec_id=$1", execId)
+	if err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		_, err = tx.Exec("INSERT INTO exec_recovery_codes (exec_id, code_hash) VALUES ($1, $2)", execId, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Versioned schema migrations, named <version>_<name>.up.sql / <version>_<name>.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Arbitrary key for pg_advisory_xact_lock, so two instances never migrate at once.
+const migrationLockKey = 824151
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Reads the embedded migrations, sorted by version.
+func loadMigrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %q", fileName)
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q", fileName)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+func appliedMigrations(db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Runs a single migration step inside a transaction holding the migration lock.
+// The applied check is repeated under the lock, so a concurrent run is a no-op.
+func runMigration(db *sql.DB, migration Migration, up bool) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockKey)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	var applied bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version=$1)", migration.Version).Scan(&applied)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if applied == up {
+		tx.Rollback()
+		return false, nil
+	}
+
+	if up {
+		_, err = tx.Exec(migration.Up)
+		if err == nil {
+			_, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+		}
+	} else {
+		_, err = tx.Exec(migration.Down)
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM schema_migrations WHERE version=$1", migration.Version)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Applies every pending migration in order, returns the number applied.
+func MigrateUp() (int, error) {
+	db, err := getDB()
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "Error loading migrations.")
+	}
+	err = ensureMigrationsTable(db)
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "Error creating schema_migrations table.")
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		ran, err := runMigration(db, migration, true)
+		if err != nil {
+			return count, utils.ErrorHandler(err, fmt.Sprintf("Error applying migration %d_%s.", migration.Version, migration.Name))
+		}
+		if ran {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Rolls back the latest `steps` applied migrations, returns the number rolled back.
+func MigrateDown(steps int) (int, error) {
+	db, err := getDB()
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "Error loading migrations.")
+	}
+	err = ensureMigrationsTable(db)
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "Error creating schema_migrations table.")
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "Error reading schema_migrations.")
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return count, utils.ErrorHandler(fmt.Errorf("migration %d has no down file", migration.Version), "Error rolling back migrations.")
+		}
+		ran, err := runMigration(db, migration, false)
+		if err != nil {
+			return count, utils.ErrorHandler(err, fmt.Sprintf("Error rolling back migration %d_%s.", migration.Version, migration.Name))
+		}
+		if ran {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Lists every embedded migration along with whether it has been applied.
+func GetMigrationStatus() ([]MigrationStatus, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error loading migrations.")
+	}
+	err = ensureMigrationsTable(db)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error creating schema_migrations table.")
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error reading schema_migrations.")
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses[i] = MigrationStatus{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		}
+	}
+	return statuses, nil
+}
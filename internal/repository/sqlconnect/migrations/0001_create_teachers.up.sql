@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS teachers (
+    id SERIAL PRIMARY KEY,
+    first_name VARCHAR(255) NOT NULL,
+    last_name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    class VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_teachers_class ON teachers (class);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS students (
+    id SERIAL PRIMARY KEY,
+    first_name VARCHAR(255) NOT NULL,
+    last_name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    class VARCHAR(255) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_students_class ON students (class);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS execs (
+    id SERIAL PRIMARY KEY,
+    first_name VARCHAR(255) NOT NULL,
+    last_name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    username VARCHAR(255) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    password_changed_at TIMESTAMP,
+    user_created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    password_reset_token VARCHAR(255),
+    password_token_expires TIMESTAMP,
+    inactive_status BOOLEAN NOT NULL DEFAULT FALSE,
+    role VARCHAR(50) NOT NULL DEFAULT 'exec'
+);
+
+CREATE INDEX IF NOT EXISTS idx_execs_password_reset_token ON execs (password_reset_token);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_students_created_at;
+DROP INDEX IF EXISTS idx_teachers_created_at;
+
+ALTER TABLE students DROP COLUMN IF EXISTS created_at;
+ALTER TABLE teachers DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,5 @@
+ALTER TABLE teachers ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE students ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_teachers_created_at ON teachers (created_at);
+CREATE INDEX IF NOT EXISTS idx_students_created_at ON students (created_at);
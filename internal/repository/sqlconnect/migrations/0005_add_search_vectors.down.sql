@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_execs_search;
+DROP INDEX IF EXISTS idx_students_search;
+DROP INDEX IF EXISTS idx_teachers_search;
+
+ALTER TABLE execs DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE students DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE teachers DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,17 @@
+-- Full-text search over people, 'simple' keeps names as is (no stemming or stop words).
+-- Emails are indexed whole and split on @ and dots, so "school" finds "jo@school.com".
+ALTER TABLE teachers ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || regexp_replace(email, '[@.]', ' ', 'g') || ' ' || class || ' ' || subject)
+) STORED;
+
+ALTER TABLE students ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || regexp_replace(email, '[@.]', ' ', 'g') || ' ' || class)
+) STORED;
+
+ALTER TABLE execs ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || regexp_replace(email, '[@.]', ' ', 'g') || ' ' || username)
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_teachers_search ON teachers USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_students_search ON students USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_execs_search ON execs USING GIN (search_vector);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS exec_recovery_codes;
+
+ALTER TABLE execs
+    DROP COLUMN IF EXISTS mfa_last_step,
+    DROP COLUMN IF EXISTS mfa_enabled,
+    DROP COLUMN IF EXISTS mfa_secret;
@@ -0,0 +1,15 @@
+-- TOTP two-factor authentication. mfa_secret is set on enrollment, mfa_enabled once the
+-- first code is verified. mfa_last_step stops a code from being replayed.
+ALTER TABLE execs
+    ADD COLUMN IF NOT EXISTS mfa_secret VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS mfa_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS mfa_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- Single use recovery codes, stored hashed.
+CREATE TABLE IF NOT EXISTS exec_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    exec_id INTEGER NOT NULL REFERENCES execs (id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    UNIQUE (exec_id, code_hash)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE execs
+    DROP COLUMN IF EXISTS locked_until,
+    DROP COLUMN IF EXISTS last_failed_login_at,
+    DROP COLUMN IF EXISTS failed_login_attempts;
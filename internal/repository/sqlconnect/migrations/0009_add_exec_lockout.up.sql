@@ -0,0 +1,5 @@
+-- Failed logins per exec, used for the login backoff and the temporary lockout.
+ALTER TABLE execs
+    ADD COLUMN IF NOT EXISTS failed_login_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
@@ -0,0 +1,14 @@
+-- API keys for service-to-service access. Only the sha256 hash of the key is stored,
+-- prefix is kept to tell keys apart in listings.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER REFERENCES execs (id) ON DELETE SET NULL
+);
@@ -0,0 +1,16 @@
+-- One row per login. expires_at follows the refresh token, deleting the row signs the
+-- login out and, through session_id, removes its refresh tokens.
+CREATE TABLE IF NOT EXISTS sessions (
+    id SERIAL PRIMARY KEY,
+    exec_id INTEGER NOT NULL REFERENCES execs (id) ON DELETE CASCADE,
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_exec_id ON sessions (exec_id);
+
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS session_id INTEGER REFERENCES sessions (id) ON DELETE CASCADE;
@@ -0,0 +1,10 @@
+-- Previous password hashes of each exec, so recent passwords can't be reused. The current
+-- one stays in execs.password, only the newest PASSWORD_HISTORY - 1 rows are kept.
+CREATE TABLE IF NOT EXISTS password_history (
+    id SERIAL PRIMARY KEY,
+    exec_id INTEGER NOT NULL REFERENCES execs (id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_exec_id ON password_history (exec_id, id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_execs_invite_token;
+
+ALTER TABLE execs
+    DROP COLUMN IF EXISTS invite_token_expires,
+    DROP COLUMN IF EXISTS invite_token;
@@ -0,0 +1,7 @@
+-- Execs created without a password get a single-use invitation code, stored hashed like the
+-- password reset token. password stays '' until the invitation is accepted.
+ALTER TABLE execs
+    ADD COLUMN IF NOT EXISTS invite_token VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS invite_token_expires TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_execs_invite_token ON execs (invite_token);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_execs_magic_link_token;
+
+ALTER TABLE execs
+    DROP COLUMN IF EXISTS magic_link_expires,
+    DROP COLUMN IF EXISTS magic_link_token;
@@ -0,0 +1,7 @@
+-- Single-use code of the last magic login link sent to an exec, stored hashed like the
+-- password reset token.
+ALTER TABLE execs
+    ADD COLUMN IF NOT EXISTS magic_link_token VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS magic_link_expires TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_execs_magic_link_token ON execs (magic_link_token);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS passkey_challenges;
+
+DROP TABLE IF EXISTS passkeys;
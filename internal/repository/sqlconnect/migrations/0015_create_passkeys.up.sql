@@ -0,0 +1,22 @@
+-- WebAuthn credentials of execs. credential_id is base64url, up to 1023 bytes decoded.
+CREATE TABLE IF NOT EXISTS passkeys (
+    id SERIAL PRIMARY KEY,
+    exec_id INTEGER NOT NULL REFERENCES execs (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    credential_id VARCHAR(1400) NOT NULL UNIQUE,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aaguid VARCHAR(36) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_passkeys_exec_id ON passkeys (exec_id);
+
+-- Challenges of ceremonies in progress, deleted once answered. exec_id is NULL for logins.
+CREATE TABLE IF NOT EXISTS passkey_challenges (
+    challenge VARCHAR(64) PRIMARY KEY,
+    exec_id INTEGER REFERENCES execs (id) ON DELETE CASCADE,
+    ceremony VARCHAR(16) NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
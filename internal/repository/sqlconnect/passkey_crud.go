@@ -0,0 +1,155 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+const passkeyColumns = "id, exec_id, name, credential_id, public_key, sign_count, aaguid, created_at, last_used_at"
+
+func CreatePasskeyChallengeDBHandler(challenge models.PasskeyChallenge) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	_, err = db.Exec("DELETE FROM passkey_challenges WHERE expires_at < $1", time.Now().UTC())
+	if err != nil {
+		return utils.ErrorHandler(err, "Error creating passkey challenge.")
+	}
+	execId := sql.NullInt64{Int64: int64(challenge.ExecID), Valid: challenge.ExecID != 0}
+	_, err = db.Exec("INSERT INTO passkey_challenges (challenge, exec_id, ceremony, expires_at) VALUES ($1, $2, $3, $4)", challenge.Challenge, execId, challenge.Ceremony, challenge.ExpiresAt.UTC())
+	if err != nil {
+		return dbErrorHandler(err, "Error creating passkey challenge.")
+	}
+	return nil
+}
+
+func ConsumePasskeyChallengeDBHandler(challenge string, ceremony string) (models.PasskeyChallenge, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.PasskeyChallenge{}, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	var stored models.PasskeyChallenge
+	var execId sql.NullInt64
+	err = db.QueryRow("DELETE FROM passkey_challenges WHERE challenge=$1 AND ceremony=$2 AND expires_at >= $3 RETURNING challenge, exec_id, ceremony, expires_at", challenge, ceremony, time.Now().UTC()).Scan(&stored.Challenge, &execId, &stored.Ceremony, &stored.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return models.PasskeyChallenge{}, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Invalid / Expired passkey challenge.")
+	} else if err != nil {
+		return models.PasskeyChallenge{}, utils.ErrorHandler(err, "Error retrieving passkey challenge.")
+	}
+	stored.ExecID = int(execId.Int64)
+	return stored, nil
+}
+
+func CreatePasskeyDBHandler(passkey models.Passkey) (models.Passkey, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Passkey{}, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	now := time.Now().UTC()
+	passkey.CreatedAt = now
+	passkey.LastUsedAt = now
+	err = db.QueryRow("INSERT INTO passkeys (exec_id, name, credential_id, public_key, sign_count, aaguid, created_at, last_used_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id", passkey.ExecID, passkey.Name, passkey.CredentialID, passkey.PublicKey, int64(passkey.SignCount), passkey.AAGUID, passkey.CreatedAt, passkey.LastUsedAt).Scan(&passkey.ID)
+	if err != nil {
+		return models.Passkey{}, dbErrorHandler(err, "Error registering passkey.")
+	}
+	return passkey, nil
+}
+
+func GetExecPasskeysDBHandler(execId int) ([]models.Passkey, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	rows, err := db.Query("SELECT "+passkeyColumns+" FROM passkeys WHERE exec_id=$1 ORDER BY id", execId)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error retrieving passkeys.")
+	}
+	defer rows.Close()
+
+	passkeys := make([]models.Passkey, 0)
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "Error retrieving passkeys.")
+		}
+		passkeys = append(passkeys, passkey)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error retrieving passkeys.")
+	}
+	return passkeys, nil
+}
+
+func GetPasskeyByCredentialIDDBHandler(credentialId string) (models.Passkey, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Passkey{}, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	passkey, err := scanPasskey(db.QueryRow("SELECT "+passkeyColumns+" FROM passkeys WHERE credential_id=$1", credentialId))
+	if err == sql.ErrNoRows {
+		return models.Passkey{}, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Unknown passkey.")
+	} else if err != nil {
+		return models.Passkey{}, utils.ErrorHandler(err, "Error retrieving passkey.")
+	}
+	return passkey, nil
+}
+
+func UsePasskeyDBHandler(passkeyId int, oldSignCount uint32, signCount uint32, usedAt time.Time) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	res, err := db.Exec("UPDATE passkeys SET sign_count=$1, last_used_at=$2 WHERE id=$3 AND sign_count=$4", int64(signCount), usedAt.UTC(), passkeyId, int64(oldSignCount))
+	if err != nil {
+		return utils.ErrorHandler(err, "Error updating passkey.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error updating passkey.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(errors.New("sign count changed"), utils.ErrUnauthorized, "Passkey was used by another login, try again.")
+	}
+	return nil
+}
+
+func DeletePasskeyDBHandler(execId int, passkeyId int) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	res, err := db.Exec("DELETE FROM passkeys WHERE id=$1 AND exec_id=$2", passkeyId, execId)
+	if err != nil {
+		return utils.ErrorHandler(err, "Error deleting passkey.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error deleting passkey.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("Passkey %d not found.", passkeyId))
+	}
+	return nil
+}
+
+func scanPasskey(row interface{ Scan(dest ...any) error }) (models.Passkey, error) {
+	var passkey models.Passkey
+	var signCount int64
+	err := row.Scan(&passkey.ID, &passkey.ExecID, &passkey.Name, &passkey.CredentialID, &passkey.PublicKey, &signCount, &passkey.AAGUID, &passkey.CreatedAt, &passkey.LastUsedAt)
+	passkey.SignCount = uint32(signCount)
+	return passkey, err
+}
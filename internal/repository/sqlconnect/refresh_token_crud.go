@@ -0,0 +1,142 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+func CreateRefreshTokenDBHandler(token models.RefreshToken) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	sessionId := sql.NullInt64{Int64: int64(token.SessionID), Valid: token.SessionID != 0}
+	_, err = db.Exec("INSERT INTO refresh_tokens (exec_id, token_hash, family_id, expires_at, session_id) VALUES ($1, $2, $3, $4, $5)", token.ExecID, token.TokenHash, token.FamilyID, token.ExpiresAt, sessionId)
+	if err != nil {
+		return dbErrorHandler(err, "Error storing refresh token.")
+	}
+	return nil
+}
+
+func RotateRefreshTokenDBHandler(hashedToken string, next models.RefreshToken) (models.Exec, int, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Exec{}, 0, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Exec{}, 0, utils.ErrorHandler(err, "Error refreshing token.")
+	}
+
+	// Lock the row, so two concurrent refreshes with the same token can't both succeed.
+	var current models.RefreshToken
+	var sessionId sql.NullInt64
+	err = tx.QueryRow("SELECT id, exec_id, family_id, expires_at, used_at, revoked_at, session_id FROM refresh_tokens WHERE token_hash=$1 FOR UPDATE", hashedToken).Scan(&current.ID, &current.ExecID, &current.FamilyID, &current.ExpiresAt, &current.UsedAt, &current.RevokedAt, &sessionId)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.Exec{}, 0, utils.TypedErrorHandler(err, utils.ErrUnauthorized, "Invalid refresh token.")
+	} else if err != nil {
+		tx.Rollback()
+		return models.Exec{}, 0, utils.ErrorHandler(err, "Error refreshing token.")
+	}
+
+	if current.RevokedAt.Valid {
+		tx.Rollback()
+		return models.Exec{}, 0, utils.TypedErrorHandler(errors.New("refresh token revoked"), utils.ErrUnauthorized, "Refresh token revoked, please log in again.")
+	}
+	if current.UsedAt.Valid {
+		// The token was already rotated, so either the client or an attacker holds a stolen copy.
+		// Ending the session also stops the access tokens already issued to the family.
+		_, err = tx.Exec("UPDATE refresh_tokens SET revoked_at=$1 WHERE family_id=$2 AND revoked_at IS NULL", time.Now(), current.FamilyID)
+		if err != nil {
+			tx.Rollback()
+			return models.Exec{}, 0, utils.ErrorHandler(err, "Error refreshing token.")
+		}
+		_, err = tx.Exec("DELETE FROM sessions WHERE id=$1", sessionId)
+		if err != nil {
+			tx.Rollback()
+			return models.Exec{}, 0, utils.ErrorHandler(err, "Error refreshing token.")
+		}
+		err = tx.Commit()
+		if err != nil {
+			return models.Exec{}, 0, utils.ErrorHandler(err, "Error refreshing token.")
+		}
+		return models.Exec{}, 0, utils.TypedErrorHandler(errors.New("refresh token reused"), utils.ErrUnauthorized, "Refresh token reuse detected, please log in again.")
+	}
+	if time.Now().After(current.ExpiresAt) {
+		tx.Rollback()
+		return models.Exec{}, 0, utils.TypedErrorHandler(errors.New("refresh token expired"), utils.ErrUnauthorized, "Refresh token expired, please log in again.")
+	}
+
+	var exec models.Exec
+	err = tx.QueryRow("SELECT id, username, inactive_status, role FROM execs WHERE id=$1", current.ExecID).Scan(&exec.ID, &exec.Username, &exec.InactiveStatus, &exec.Role)
+	if err != nil {
+		tx.Rollback()
+		return models.Exec{}, 0, dbErrorHandler(err, "Error refreshing token.")
+	}
+	if exec.InactiveStatus {
+		tx.Rollback()
+		return models.Exec{}, 0, utils.TypedErrorHandler(errors.New("account is inactive"), utils.ErrForbidden, "Account is inactive.")
+	}
+
+	_, err = tx.Exec("UPDATE refresh_tokens SET used_at=$1 WHERE id=$2", time.Now(), current.ID)
+	if err != nil {
+		tx.Rollback()
+		return models.Exec{}, 0, utils.ErrorHandler(err, "Error refreshing token.")
+	}
+	_, err = tx.Exec("INSERT INTO refresh_tokens (exec_id, token_hash, family_id, expires_at, session_id) VALUES ($1, $2, $3, $4, $5)", current.ExecID, next.TokenHash, current.FamilyID, next.ExpiresAt, sessionId)
+	if err != nil {
+		tx.Rollback()
+		return models.Exec{}, 0, dbErrorHandler(err, "Error refreshing token.")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.Exec{}, 0, utils.ErrorHandler(err, "Error refreshing token.")
+	}
+	return exec, int(sessionId.Int64), nil
+}
+
+func RevokeRefreshTokenFamilyDBHandler(hashedToken string) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error revoking refresh token.")
+	}
+
+	res, err := tx.Exec("UPDATE refresh_tokens SET revoked_at=$1 WHERE revoked_at IS NULL AND family_id=(SELECT family_id FROM refresh_tokens WHERE token_hash=$2)", time.Now(), hashedToken)
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Error revoking refresh token.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Error revoking refresh token.")
+	}
+	// The session is signed out with its refresh tokens, also when the access token already expired.
+	_, err = tx.Exec("DELETE FROM sessions WHERE id=(SELECT session_id FROM refresh_tokens WHERE token_hash=$1)", hashedToken)
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "Error revoking refresh token.")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error revoking refresh token.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, "Refresh token not found.")
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package sqlconnect
+
+import (
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository"
+)
+
+// Postgres backed repositories, all sharing the handle injected via SetDB.
+func NewRepositories() repository.Repositories {
+	return repository.Repositories{
+		Teachers:      TeacherRepository{},
+		Students:      StudentRepository{},
+		Execs:         ExecRepository{},
+		Search:        SearchRepository{},
+		RefreshTokens: RefreshTokenRepository{},
+		RevokedTokens: RevokedTokenRepository{},
+		MFA:           MFARepository{},
+		APIKeys:       APIKeyRepository{},
+		Sessions:      SessionRepository{},
+		Passkeys:      PasskeyRepository{},
+	}
+}
+
+type TeacherRepository struct{}
+
+func (TeacherRepository) GetTeachers(opts repository.ListOptions) ([]models.Teacher, int, error) {
+	return GetTeachersDBHandler(opts)
+}
+
+func (TeacherRepository) GetOneTeacher(teacherId int) (models.Teacher, error) {
+	return GetOneTeacherDBHandler(teacherId)
+}
+
+func (TeacherRepository) GetStudentsByTeacherID(teacherId int) ([]models.Student, error) {
+	return GetStudentsByTeachersIDDBHandler(teacherId, []models.Student{})
+}
+
+func (TeacherRepository) GetStudentCountByTeacherID(teacherId int) (int, error) {
+	return GetStudentCountByTeacherIDDBHandler(teacherId)
+}
+
+func (TeacherRepository) PostTeachers(newTeachers []models.Teacher) ([]models.Teacher, error) {
+	return PostTeachersDBHandler(newTeachers)
+}
+
+func (TeacherRepository) PutOneTeacher(teacherId int, updatedTchr models.Teacher) error {
+	return PutOneTeacherDBHandler(teacherId, updatedTchr)
+}
+
+func (TeacherRepository) PatchOneTeacher(teacherId int, updates map[string]interface{}) (models.Teacher, error) {
+	return PatchOneTeacherDBHandler(teacherId, updates)
+}
+
+func (TeacherRepository) PatchTeachers(updates []map[string]interface{}) ([]models.Teacher, error) {
+	return PatchTeachersDBHandler(updates)
+}
+
+func (TeacherRepository) DeleteOneTeacher(teacherId int) error {
+	return DeleteOneTeacherDBHandler(teacherId)
+}
+
+func (TeacherRepository) DeleteTeachers(ids []int) error {
+	return DeleteTeachersDBHandler(ids)
+}
+
+type StudentRepository struct{}
+
+func (StudentRepository) GetStudents(opts repository.ListOptions) ([]models.Student, int, error) {
+	return GetStudentsDBHandler(opts)
+}
+
+func (StudentRepository) GetOneStudent(studentId int) (models.Student, error) {
+	return GetOneStudentDBHandler(studentId)
+}
+
+func (StudentRepository) PostStudents(newStudents []models.Student) ([]models.Student, error) {
+	return PostStudentsDBHandler(newStudents)
+}
+
+func (StudentRepository) PutOneStudent(studentId int, updatedSdnt models.Student) error {
+	return PutOneStudentDBHandler(studentId, updatedSdnt)
+}
+
+func (StudentRepository) PatchOneStudent(studentId int, updates map[string]interface{}) (models.Student, error) {
+	return PatchOneStudentDBHandler(studentId, updates)
+}
+
+func (StudentRepository) PatchStudents(updates []map[string]interface{}) ([]models.Student, error) {
+	return PatchStudentsDBHandler(updates)
+}
+
+func (StudentRepository) DeleteOneStudent(studentId int) error {
+	return DeleteOneStudentDBHandler(studentId)
+}
+
+func (StudentRepository) DeleteStudents(ids []int) error {
+	return DeleteStudentsDBHandler(ids)
+}
+
+type ExecRepository struct{}
+
+func (ExecRepository) GetExecs(opts repository.ListOptions) ([]models.Exec, int, error) {
+	return GetExecsDBHandler(opts)
+}
+
+func (ExecRepository) GetOneExec(execId int) (models.Exec, error) {
+	return GetOneExecDBHandler(execId)
+}
+
+func (ExecRepository) PostExecs(newExecs []models.Exec) ([]models.Exec, error) {
+	return PostExecsDBHandler(newExecs)
+}
+
+func (ExecRepository) PatchOneExec(execId int, updates map[string]interface{}) (models.Exec, error) {
+	return PatchOneExecDBHandler(execId, updates)
+}
+
+func (ExecRepository) PatchExecs(updates []map[string]interface{}) ([]models.Exec, error) {
+	return PatchExecsDBHandler(updates)
+}
+
+func (ExecRepository) DeleteOneExec(execId int) error {
+	return DeleteOneExecDBHandler(execId)
+}
+
+func (ExecRepository) LoginExec(req models.Exec) (models.Exec, error) {
+	return LoginExecDBHandler(req)
+}
+
+func (ExecRepository) UpdateExecPassword(execId int, req models.UpdatePasswordRequest) (string, string, error) {
+	return UpdateExecPasswordDBHandler(execId, req)
+}
+
+func (ExecRepository) ForgotExecPassword(execEmail string) (time.Duration, string, error) {
+	return ForgotExecPasswordDBHandler(execEmail)
+}
+
+func (ExecRepository) ResetPassword(hashedTokenString string, hashedPwd string) error {
+	return ResetPasswordDBHandler(hashedTokenString, hashedPwd)
+}
+
+func (ExecRepository) GetExecAuthState(execId int) (models.Exec, error) {
+	return GetExecAuthStateDBHandler(execId)
+}
+
+func (ExecRepository) RecordFailedLogin(execId int, at time.Time, since time.Time) (int, error) {
+	return RecordFailedLoginDBHandler(execId, at, since)
+}
+
+func (ExecRepository) LockExec(execId int, until time.Time) error {
+	return LockExecDBHandler(execId, until)
+}
+
+func (ExecRepository) ResetFailedLogins(execId int) error {
+	return ResetFailedLoginsDBHandler(execId)
+}
+
+func (ExecRepository) RehashPassword(execId int, oldHash string, newHash string) error {
+	return RehashPasswordDBHandler(execId, oldHash, newHash)
+}
+
+func (ExecRepository) GetPasswordHistory(execId int, limit int) ([]string, error) {
+	return GetPasswordHistoryDBHandler(execId, limit)
+}
+
+func (ExecRepository) GetExecByResetToken(hashedTokenString string) (models.Exec, error) {
+	return GetExecByResetTokenDBHandler(hashedTokenString)
+}
+
+func (ExecRepository) ReissueExecInvite(execId int, hashedTokenString string, expiresAt time.Time) (models.Exec, error) {
+	return ReissueExecInviteDBHandler(execId, hashedTokenString, expiresAt)
+}
+
+func (ExecRepository) GetExecByInviteToken(hashedTokenString string) (models.Exec, error) {
+	return GetExecByInviteTokenDBHandler(hashedTokenString)
+}
+
+func (ExecRepository) AcceptExecInvite(hashedTokenString string, hashedPwd string) error {
+	return AcceptExecInviteDBHandler(hashedTokenString, hashedPwd)
+}
+
+func (ExecRepository) CreateMagicLink(execEmail string, hashedTokenString string, expiresAt time.Time) (models.Exec, error) {
+	return CreateMagicLinkDBHandler(execEmail, hashedTokenString, expiresAt)
+}
+
+func (ExecRepository) ConsumeMagicLink(hashedTokenString string) (models.Exec, error) {
+	return ConsumeMagicLinkDBHandler(hashedTokenString)
+}
+
+type SearchRepository struct{}
+
+func (SearchRepository) Search(terms []string, page int, limit int) ([]models.SearchResult, int, error) {
+	return SearchDBHandler(terms, page, limit)
+}
+
+type RefreshTokenRepository struct{}
+
+func (RefreshTokenRepository) CreateRefreshToken(token models.RefreshToken) error {
+	return CreateRefreshTokenDBHandler(token)
+}
+
+func (RefreshTokenRepository) RotateRefreshToken(hashedToken string, next models.RefreshToken) (models.Exec, int, error) {
+	return RotateRefreshTokenDBHandler(hashedToken, next)
+}
+
+func (RefreshTokenRepository) RevokeRefreshTokenFamily(hashedToken string) error {
+	return RevokeRefreshTokenFamilyDBHandler(hashedToken)
+}
+
+type RevokedTokenRepository struct{}
+
+func (RevokedTokenRepository) RevokeToken(jti string, execId int, expiresAt time.Time) error {
+	return RevokeTokenDBHandler(jti, execId, expiresAt)
+}
+
+func (RevokedTokenRepository) IsTokenRevoked(jti string) (bool, error) {
+	return IsTokenRevokedDBHandler(jti)
+}
+
+func (RevokedTokenRepository) UseToken(jti string, execId int, expiresAt time.Time) error {
+	return UseTokenDBHandler(jti, execId, expiresAt)
+}
+
+type MFARepository struct{}
+
+func (MFARepository) GetMFA(execId int) (models.ExecMFA, error) {
+	return GetMFADBHandler(execId)
+}
+
+func (MFARepository) SetMFASecret(execId int, secret string) error {
+	return SetMFASecretDBHandler(execId, secret)
+}
+
+func (MFARepository) EnableMFA(execId int, recoveryCodeHashes []string) error {
+	return EnableMFADBHandler(execId, recoveryCodeHashes)
+}
+
+func (MFARepository) DisableMFA(execId int) error {
+	return DisableMFADBHandler(execId)
+}
+
+func (MFARepository) UseTOTPStep(execId int, step int64) error {
+	return UseTOTPStepDBHandler(execId, step)
+}
+
+func (MFARepository) UseRecoveryCode(execId int, codeHash string) error {
+	return UseRecoveryCodeDBHandler(execId, codeHash)
+}
+
+func (MFARepository) ReplaceRecoveryCodes(execId int, codeHashes []string) error {
+	return ReplaceRecoveryCodesDBHandler(execId, codeHashes)
+}
+
+type APIKeyRepository struct{}
+
+func (APIKeyRepository) CreateAPIKey(key models.APIKey) (models.APIKey, error) {
+	return CreateAPIKeyDBHandler(key)
+}
+
+func (APIKeyRepository) GetAPIKeys() ([]models.APIKey, error) {
+	return GetAPIKeysDBHandler()
+}
+
+func (APIKeyRepository) GetAPIKeyByHash(keyHash string) (models.APIKey, error) {
+	return GetAPIKeyByHashDBHandler(keyHash)
+}
+
+func (APIKeyRepository) TouchAPIKey(keyId int, usedAt time.Time) error {
+	return TouchAPIKeyDBHandler(keyId, usedAt)
+}
+
+func (APIKeyRepository) DeleteAPIKey(keyId int) error {
+	return DeleteAPIKeyDBHandler(keyId)
+}
+
+type SessionRepository struct{}
+
+func (SessionRepository) CreateSession(session models.Session) (models.Session, error) {
+	return CreateSessionDBHandler(session)
+}
+
+func (SessionRepository) GetSession(sessionId int) (models.Session, error) {
+	return GetSessionDBHandler(sessionId)
+}
+
+func (SessionRepository) GetExecSessions(execId int) ([]models.Session, error) {
+	return GetExecSessionsDBHandler(execId)
+}
+
+func (SessionRepository) TouchSession(sessionId int, lastSeenAt time.Time, expiresAt time.Time) error {
+	return TouchSessionDBHandler(sessionId, lastSeenAt, expiresAt)
+}
+
+func (SessionRepository) DeleteSession(execId int, sessionId int) error {
+	return DeleteSessionDBHandler(execId, sessionId)
+}
+
+func (SessionRepository) DeleteExecSessions(execId int) (int, error) {
+	return DeleteExecSessionsDBHandler(execId)
+}
+
+type PasskeyRepository struct{}
+
+func (PasskeyRepository) CreatePasskeyChallenge(challenge models.PasskeyChallenge) error {
+	return CreatePasskeyChallengeDBHandler(challenge)
+}
+
+func (PasskeyRepository) ConsumePasskeyChallenge(challenge string, ceremony string) (models.PasskeyChallenge, error) {
+	return ConsumePasskeyChallengeDBHandler(challenge, ceremony)
+}
+
+func (PasskeyRepository) CreatePasskey(passkey models.Passkey) (models.Passkey, error) {
+	return CreatePasskeyDBHandler(passkey)
+}
+
+func (PasskeyRepository) GetExecPasskeys(execId int) ([]models.Passkey, error) {
+	return GetExecPasskeysDBHandler(execId)
+}
+
+func (PasskeyRepository) GetPasskeyByCredentialID(credentialId string) (models.Passkey, error) {
+	return GetPasskeyByCredentialIDDBHandler(credentialId)
+}
+
+func (PasskeyRepository) UsePasskey(passkeyId int, oldSignCount uint32, signCount uint32, usedAt time.Time) error {
+	return UsePasskeyDBHandler(passkeyId, oldSignCount, signCount, usedAt)
+}
+
+func (PasskeyRepository) DeletePasskey(execId int, passkeyId int) error {
+	return DeletePasskeyDBHandler(execId, passkeyId)
+}
@@ -0,0 +1,62 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+func RevokeTokenDBHandler(jti string, execId int, expiresAt time.Time) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	_, err = db.Exec("INSERT INTO revoked_tokens (jti, exec_id, expires_at) VALUES ($1, $2, $3) ON CONFLICT (jti) DO NOTHING", jti, execId, expiresAt)
+	if err != nil {
+		return utils.ErrorHandler(err, "Error revoking token.")
+	}
+
+	// Expired tokens are rejected anyway, so their rows can go.
+	_, err = db.Exec("DELETE FROM revoked_tokens WHERE expires_at < $1", time.Now())
+	if err != nil {
+		utils.ErrorHandler(err, "Error cleaning up revoked tokens.")
+	}
+	return nil
+}
+
+func UseTokenDBHandler(jti string, execId int, expiresAt time.Time) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	// The primary key on jti lets a single insert through.
+	res, err := db.Exec("INSERT INTO revoked_tokens (jti, exec_id, expires_at) VALUES ($1, $2, $3) ON CONFLICT (jti) DO NOTHING", jti, execId, expiresAt)
+	if err != nil {
+		return utils.ErrorHandler(err, "Error using token.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error using token.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrUnauthorized, "Token has already been used, please log in again.")
+	}
+	return nil
+}
+
+func IsTokenRevokedDBHandler(jti string) (bool, error) {
+	db, err := getDB()
+	if err != nil {
+		return false, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	var revoked bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti=$1)", jti).Scan(&revoked)
+	if err != nil {
+		return false, utils.ErrorHandler(err, "Error checking token.")
+	}
+	return revoked, nil
+}
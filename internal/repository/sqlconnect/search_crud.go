@@ -0,0 +1,52 @@
+package sqlconnect
+
+import (
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+// Teachers and students matching the tsquery in $1, see migration 0005 for the search vectors.
+const searchResultsQuery = `WITH query AS (SELECT to_tsquery('simple', $1) AS q),
+results AS (
+	SELECT 'teacher' AS type, id, first_name, last_name, email, class, subject, ts_rank(search_vector, query.q) AS rank
+	FROM teachers, query WHERE search_vector @@ query.q
+	UNION ALL
+	SELECT 'student' AS type, id, first_name, last_name, email, class, '' AS subject, ts_rank(search_vector, query.q) AS rank
+	FROM students, query WHERE search_vector @@ query.q
+)`
+
+func SearchDBHandler(terms []string, page int, limit int) ([]models.SearchResult, int, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, 0, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	tsQuery := buildTSQuery(terms)
+	offset := (page - 1) * limit
+	rows, err := db.Query(searchResultsQuery+" SELECT type, id, first_name, last_name, email, class, subject, rank FROM results ORDER BY rank DESC, type, id LIMIT $2 OFFSET $3", tsQuery, limit, offset)
+	if err != nil {
+		return nil, 0, utils.ErrorHandler(err, "Error searching.")
+	}
+	defer rows.Close()
+
+	results := make([]models.SearchResult, 0)
+	for rows.Next() {
+		var result models.SearchResult
+		err = rows.Scan(&result.Type, &result.ID, &result.FirstName, &result.LastName, &result.Email, &result.Class, &result.Subject, &result.Rank)
+		if err != nil {
+			return nil, 0, utils.ErrorHandler(err, "Error searching.")
+		}
+		results = append(results, result)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, 0, utils.ErrorHandler(err, "Error searching.")
+	}
+
+	var total int
+	err = db.QueryRow(searchResultsQuery+" SELECT COUNT(*) FROM results", tsQuery).Scan(&total)
+	if err != nil {
+		return nil, 0, utils.ErrorHandler(err, "Error counting search results.")
+	}
+	return results, total, nil
+}
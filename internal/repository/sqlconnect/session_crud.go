@@ -0,0 +1,158 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/pkg/utils"
+)
+
+const sessionColumns = "id, exec_id, user_agent, ip, created_at, last_seen_at, expires_at"
+
+func CreateSessionDBHandler(session models.Session) (models.Session, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Session{}, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	now := time.Now().UTC()
+	_, err = db.Exec("DELETE FROM sessions WHERE exec_id=$1 AND expires_at < $2", session.ExecID, now)
+	if err != nil {
+		return models.Session{}, utils.ErrorHandler(err, "Error creating session.")
+	}
+
+	session.CreatedAt = now
+	session.LastSeenAt = now
+	session.ExpiresAt = session.ExpiresAt.UTC()
+	err = db.QueryRow("INSERT INTO sessions (exec_id, user_agent, ip, created_at, last_seen_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id", session.ExecID, session.UserAgent, session.IP, session.CreatedAt, session.LastSeenAt, session.ExpiresAt).Scan(&session.ID)
+	if err != nil {
+		return models.Session{}, dbErrorHandler(err, "Error creating session.")
+	}
+	return session, nil
+}
+
+func GetSessionDBHandler(sessionId int) (models.Session, error) {
+	db, err := getDB()
+	if err != nil {
+		return models.Session{}, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	session, err := scanSession(db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id=$1 AND expires_at >= $2", sessionId, time.Now().UTC()))
+	if err == sql.ErrNoRows {
+		return models.Session{}, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Session %d not found.", sessionId))
+	} else if err != nil {
+		return models.Session{}, utils.ErrorHandler(err, "Error retrieving session.")
+	}
+	return session, nil
+}
+
+func GetExecSessionsDBHandler(execId int) ([]models.Session, error) {
+	db, err := getDB()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	rows, err := db.Query("SELECT "+sessionColumns+" FROM sessions WHERE exec_id=$1 AND expires_at >= $2 ORDER BY last_seen_at DESC, id DESC", execId, time.Now().UTC())
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error retrieving sessions.")
+	}
+	defer rows.Close()
+
+	sessions := make([]models.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "Error retrieving sessions.")
+		}
+		sessions = append(sessions, session)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "Error retrieving sessions.")
+	}
+	return sessions, nil
+}
+
+func TouchSessionDBHandler(sessionId int, lastSeenAt time.Time, expiresAt time.Time) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	if expiresAt.IsZero() {
+		_, err = db.Exec("UPDATE sessions SET last_seen_at=$1 WHERE id=$2", lastSeenAt.UTC(), sessionId)
+	} else {
+		_, err = db.Exec("UPDATE sessions SET last_seen_at=$1, expires_at=$2 WHERE id=$3", lastSeenAt.UTC(), expiresAt.UTC(), sessionId)
+	}
+	if err != nil {
+		return utils.ErrorHandler(err, "Error updating session.")
+	}
+	return nil
+}
+
+func DeleteSessionDBHandler(execId int, sessionId int) error {
+	db, err := getDB()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	// Refresh tokens of the session go with it, ON DELETE CASCADE.
+	res, err := db.Exec("DELETE FROM sessions WHERE id=$1 AND exec_id=$2", sessionId, execId)
+	if err != nil {
+		return utils.ErrorHandler(err, "Error deleting session.")
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "Error deleting session.")
+	}
+	if rowsAffected == 0 {
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("Session %d not found.", sessionId))
+	}
+	return nil
+}
+
+func DeleteExecSessionsDBHandler(execId int) (int, error) {
+	db, err := getDB()
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "Error connecting DB.")
+	}
+
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM execs WHERE id=$1)", execId).Scan(&exists)
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "Error deleting sessions.")
+	}
+	if !exists {
+		return 0, utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("Exec %d not found.", execId))
+	}
+
+	count, err := endExecSessions(db, execId)
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "Error deleting sessions.")
+	}
+	return count, nil
+}
+
+// Signs the exec out everywhere: deletes its sessions, and revokes refresh tokens issued
+// before sessions existed. Used on password changes too, inside their transaction.
+func endExecSessions(db sqlExecer, execId int) (int, error) {
+	_, err := db.Exec("UPDATE refresh_tokens SET revoked_at=$1 WHERE exec_id=$2 AND revoked_at IS NULL", time.Now(), execId)
+	if err != nil {
+		return 0, err
+	}
+	res, err := db.Exec("DELETE FROM sessions WHERE exec_id=$1", execId)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	return int(count), err
+}
+
+// Scans a row selected with sessionColumns.
+func scanSession(row interface{ Scan(dest ...any) error }) (models.Session, error) {
+	var session models.Session
+	err := row.Scan(&session.ID, &session.ExecID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+	return session, err
+}
@@ -1,19 +1,72 @@
 package sqlconnect
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"time"
+
+	"github.com/brickster241/rest-go/pkg/utils"
 )
 
-func ConnectDB() (*sql.DB, error){
+// Pool settings for the shared database handle.
+type DBConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	PingTimeout     time.Duration
+}
+
+// Shared handle used by every repository function. Set once at startup via SetDB.
+var db *sql.DB
+
+// Reads pool limits from env vars, falling back to sane defaults.
+func LoadDBConfig() DBConfig {
+	return DBConfig{
+		MaxOpenConns:    utils.EnvInt("DB_MAX_OPEN_CONNS", 25, 0),
+		MaxIdleConns:    utils.EnvInt("DB_MAX_IDLE_CONNS", 25, 0),
+		ConnMaxLifetime: utils.EnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute, 0),
+		ConnMaxIdleTime: utils.EnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute, 0),
+		PingTimeout:     utils.EnvDuration("DB_PING_TIMEOUT", 5*time.Second, time.Millisecond),
+	}
+}
+
+// Opens the connection pool and pings it, so the server fails fast when the DB is down.
+func ConnectDB(cfg DBConfig) (*sql.DB, error) {
 	connectionString := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=require", os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"), os.Getenv("DB_HOST"), os.Getenv("DB_PORT"))
-	db, err := sql.Open("postgres", connectionString)
+	handle, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	handle.SetMaxOpenConns(cfg.MaxOpenConns)
+	handle.SetMaxIdleConns(cfg.MaxIdleConns)
+	handle.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	handle.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.PingTimeout)
+	defer cancel()
+	err = handle.PingContext(ctx)
 	if err != nil {
+		handle.Close()
 		return nil, err
 	}
 	log.Println("Connected to PostgreSQL.")
+	return handle, nil
+}
+
+// Injects the shared database handle.
+func SetDB(handle *sql.DB) {
+	db = handle
+}
+
+func getDB() (*sql.DB, error) {
+	if db == nil {
+		return nil, errors.New("database handle not initialized")
+	}
 	return db, nil
-}
\ No newline at end of file
+}
@@ -2,22 +2,27 @@ package sqlconnect
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
 
 	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository"
 	"github.com/brickster241/rest-go/pkg/utils"
 )
 
-func GetStudentsDBHandler(query string, args []interface{}) ([]models.Student, int, error) {
-	db, err := ConnectDB()
+func GetStudentsDBHandler(opts repository.ListOptions) ([]models.Student, int, error) {
+	db, err := getDB()
 	if err != nil {
 		return []models.Student{}, 0, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
-	rows, err := db.Query(query, args...)
+	where, args := buildWhereClause(opts)
+	cursorClause, cursorArgs := buildCursorClause(opts, len(args))
+	query := "SELECT id, first_name, last_name, email, class, created_at FROM students WHERE 1=1" + where + cursorClause + buildPageClause(opts)
+
+	rows, err := db.Query(query, append(args, cursorArgs...)...)
 	if err != nil {
 		return []models.Student{}, 0, utils.ErrorHandler(err, "Error fetching Students.")
 	}
@@ -28,32 +33,36 @@ func GetStudentsDBHandler(query string, args []interface{}) ([]models.Student, i
 	studentList := make([]models.Student, 0)
 	for rows.Next() {
 		var student models.Student
-		err = rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.Class)
+		err = rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.Class, &student.CreatedAt)
 		if err != nil {
 			return []models.Student{}, 0, utils.ErrorHandler(err, "Error fetching Students.")
 		}
 		studentList = append(studentList, student)
 	}
+	err = rows.Err()
+	if err != nil {
+		return []models.Student{}, 0, utils.ErrorHandler(err, "Error fetching Students.")
+	}
+
+	// Count with the same filters, so the total matches the pages.
 	var totalStudents int
-	err = db.QueryRow("SELECT COUNT(*) FROM students").Scan(&totalStudents)
+	err = db.QueryRow("SELECT COUNT(*) FROM students WHERE 1=1"+where, args...).Scan(&totalStudents)
 	if err != nil {
-		utils.ErrorHandler(err, "")
-		return studentList, 0, nil
+		return []models.Student{}, 0, utils.ErrorHandler(err, "Error counting Students.")
 	}
 	return studentList, totalStudents, nil
 }
 
 func GetOneStudentDBHandler(studentId int) (models.Student, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return models.Student{}, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	var sdnt models.Student
-	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class FROM students WHERE id = %d", studentId)).Scan(&sdnt.ID, &sdnt.FirstName, &sdnt.LastName, &sdnt.Email, &sdnt.Class)
+	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class, created_at FROM students WHERE id = %d", studentId)).Scan(&sdnt.ID, &sdnt.FirstName, &sdnt.LastName, &sdnt.Email, &sdnt.Class, &sdnt.CreatedAt)
 	if err == sql.ErrNoRows {
-		return models.Student{}, utils.ErrorHandler(err, fmt.Sprintf("Error fetching Student %d.", studentId))
+		return models.Student{}, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Student %d not found.", studentId))
 	} else if err != nil {
 		return models.Student{}, utils.ErrorHandler(err, fmt.Sprintf("Error fetching Student %d.", studentId))
 	}
@@ -61,11 +70,10 @@ func GetOneStudentDBHandler(studentId int) (models.Student, error) {
 }
 
 func PostStudentsDBHandler(newStudents []models.Student) ([]models.Student, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return nil, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	// Prepare Query
 	tx, err := db.Begin()
@@ -83,12 +91,13 @@ func PostStudentsDBHandler(newStudents []models.Student) ([]models.Student, erro
 
 	addedStudents := make([]models.Student, len(newStudents))
 	for i, newStudent := range newStudents {
+		newStudent.CreatedAt = currentTimestamp()
 		values := getStructValues(newStudent)
 		// _, err := stmt.Exec(newStudent.FirstName, newStudent.LastName, newStudent.Email, newStudent.Class)
 		_, err := stmt.Exec(values...)
 		if err != nil {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error Adding students.")
+			return nil, dbErrorHandler(err, "Error Adding students.")
 		}
 		addedStudents[i] = newStudent
 	}
@@ -101,38 +110,36 @@ func PostStudentsDBHandler(newStudents []models.Student) ([]models.Student, erro
 }
 
 func PutOneStudentDBHandler(studentId int, updatedSdnt models.Student) error {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	var existingSdnt models.Student
-	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class FROM students WHERE id = %d", studentId)).Scan(&existingSdnt.ID, &existingSdnt.FirstName, &existingSdnt.LastName, &existingSdnt.Email, &existingSdnt.Class)
+	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class, created_at FROM students WHERE id = %d", studentId)).Scan(&existingSdnt.ID, &existingSdnt.FirstName, &existingSdnt.LastName, &existingSdnt.Email, &existingSdnt.Class, &existingSdnt.CreatedAt)
 	if err == sql.ErrNoRows {
-		return utils.ErrorHandler(err, fmt.Sprintf("Error updating Student %d.", studentId))
+		return utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Student %d not found.", studentId))
 	} else if err != nil {
 		return utils.ErrorHandler(err, fmt.Sprintf("Error updating Student %d.", studentId))
 	}
 
 	_, err = db.Exec("UPDATE students SET first_name=$1, last_name=$2, email=$3, class=$4 WHERE id=$5", updatedSdnt.FirstName, updatedSdnt.LastName, updatedSdnt.Email, updatedSdnt.Class, existingSdnt.ID)
 	if err != nil {
-		return utils.ErrorHandler(err, fmt.Sprintf("Error updating Student %d.", studentId))
+		return dbErrorHandler(err, fmt.Sprintf("Error updating Student %d.", studentId))
 	}
 	return nil
 }
 
 func PatchOneStudentDBHandler(studentId int, updates map[string]interface{}) (models.Student, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return models.Student{}, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	var existingSdnt models.Student
-	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class FROM students WHERE id = %d", studentId)).Scan(&existingSdnt.ID, &existingSdnt.FirstName, &existingSdnt.LastName, &existingSdnt.Email, &existingSdnt.Class)
+	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class, created_at FROM students WHERE id = %d", studentId)).Scan(&existingSdnt.ID, &existingSdnt.FirstName, &existingSdnt.LastName, &existingSdnt.Email, &existingSdnt.Class, &existingSdnt.CreatedAt)
 	if err == sql.ErrNoRows {
-		return models.Student{}, utils.ErrorHandler(err, fmt.Sprintf("Error updating Student %d.", studentId))
+		return models.Student{}, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Student %d not found.", studentId))
 	} else if err != nil {
 		return models.Student{}, utils.ErrorHandler(err, fmt.Sprintf("Error updating Student %d.", studentId))
 	}
@@ -148,6 +155,9 @@ func PatchOneStudentDBHandler(studentId int, updates map[string]interface{}) (mo
 
 			// Check whether such key exists in fields and set its value to v
 			if json_field == k+",omitempty" && studentVal.Field(i).CanSet() {
+				if v == nil || !reflect.ValueOf(v).Type().ConvertibleTo(studentVal.Field(i).Type()) {
+					return models.Student{}, utils.TypedErrorHandler(fmt.Errorf("cannot convert %T", v), utils.ErrValidation, fmt.Sprintf("Invalid value for field %s.", k))
+				}
 				studentVal.Field(i).Set(reflect.ValueOf(v).Convert(studentVal.Field(i).Type()))
 			}
 		}
@@ -155,17 +165,16 @@ func PatchOneStudentDBHandler(studentId int, updates map[string]interface{}) (mo
 
 	_, err = db.Exec("UPDATE students SET first_name=$1, last_name=$2, email=$3, class=$4 WHERE id=$5", existingSdnt.FirstName, existingSdnt.LastName, existingSdnt.Email, existingSdnt.Class, existingSdnt.ID)
 	if err != nil {
-		return models.Student{}, utils.ErrorHandler(err, fmt.Sprintf("Error updating Student %d.", studentId))
+		return models.Student{}, dbErrorHandler(err, fmt.Sprintf("Error updating Student %d.", studentId))
 	}
 	return existingSdnt, nil
 }
 
 func PatchStudentsDBHandler(updates []map[string]interface{}) ([]models.Student, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return nil, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -177,20 +186,20 @@ func PatchStudentsDBHandler(updates []map[string]interface{}) ([]models.Student,
 		sdntIdStr, ok := update["id"].(string)
 		if !ok {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Students.")
+			return nil, utils.TypedErrorHandler(errors.New("id should be a string"), utils.ErrValidation, "Invalid Student ID.")
 		}
 
 		sdntId, err := strconv.Atoi(sdntIdStr)
 		if err != nil {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Students.")
+			return nil, utils.TypedErrorHandler(err, utils.ErrValidation, "Invalid Student ID.")
 		}
 
 		var existingSdnt models.Student
-		err = tx.QueryRow("SELECT id, first_name, last_name, email, class FROM students WHERE id = $1", sdntId).Scan(&existingSdnt.ID, &existingSdnt.FirstName, &existingSdnt.LastName, &existingSdnt.Email, &existingSdnt.Class)
+		err = tx.QueryRow("SELECT id, first_name, last_name, email, class, created_at FROM students WHERE id = $1", sdntId).Scan(&existingSdnt.ID, &existingSdnt.FirstName, &existingSdnt.LastName, &existingSdnt.Email, &existingSdnt.Class, &existingSdnt.CreatedAt)
 		if err == sql.ErrNoRows {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Students.")
+			return nil, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Student %d not found.", sdntId))
 		} else if err != nil {
 			tx.Rollback()
 			return nil, utils.ErrorHandler(err, "Error updating Students.")
@@ -210,11 +219,11 @@ func PatchStudentsDBHandler(updates []map[string]interface{}) ([]models.Student,
 
 				// Check whether such key exists in fields and set its value to v
 				if json_field == k+",omitempty" && studentVal.Field(i).CanSet() {
-					if reflect.ValueOf(v).Type().ConvertibleTo(studentVal.Field(i).Type()) {
+					if v != nil && reflect.ValueOf(v).Type().ConvertibleTo(studentVal.Field(i).Type()) {
 						studentVal.Field(i).Set(reflect.ValueOf(v).Convert(studentVal.Field(i).Type()))
 					} else {
 						tx.Rollback()
-						return nil, utils.ErrorHandler(err, "Error updating Students.")
+						return nil, utils.TypedErrorHandler(fmt.Errorf("cannot convert %T", v), utils.ErrValidation, fmt.Sprintf("Invalid value for field %s.", k))
 					}
 					break
 				}
@@ -224,7 +233,7 @@ func PatchStudentsDBHandler(updates []map[string]interface{}) ([]models.Student,
 		_, err = tx.Exec("UPDATE students SET first_name=$1, last_name=$2, email=$3, class=$4 WHERE id=$5", existingSdnt.FirstName, existingSdnt.LastName, existingSdnt.Email, existingSdnt.Class, existingSdnt.ID)
 		if err != nil {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Students.")
+			return nil, dbErrorHandler(err, "Error updating Students.")
 		}
 		existingSdnts = append(existingSdnts, existingSdnt)
 	}
@@ -237,12 +246,11 @@ func PatchStudentsDBHandler(updates []map[string]interface{}) ([]models.Student,
 }
 
 func DeleteOneStudentDBHandler(studentId int) error {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		// log.Println("Error connecting DB :", err)
 		return utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	// Perform the delete operation
 	res, err := db.Exec("DELETE FROM students WHERE id=$1", studentId)
@@ -257,17 +265,16 @@ func DeleteOneStudentDBHandler(studentId int) error {
 
 	// Operation was successful, but no rows affected i.e. invalid ID.
 	if rowsAffected == 0 {
-		return utils.ErrorHandler(err, fmt.Sprintf("Error deleting Student %d.", studentId))
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("Student %d not found.", studentId))
 	}
 	return nil
 }
 
 func DeleteStudentsDBHandler(ids []int) error {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -293,7 +300,7 @@ func DeleteStudentsDBHandler(ids []int) error {
 		// Operation was successful, but no rows affected i.e. invalid ID.
 		if rowsAffected == 0 {
 			tx.Rollback()
-			return utils.ErrorHandler(err, "Error deleting Students.")
+			return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("Student %d not found.", studentId))
 		}
 	}
 	err = tx.Commit()
@@ -2,22 +2,27 @@ package sqlconnect
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
 
 	"github.com/brickster241/rest-go/internal/models"
+	"github.com/brickster241/rest-go/internal/repository"
 	"github.com/brickster241/rest-go/pkg/utils"
 )
 
-func GetTeachersDBHandler(query string, args []interface{}) ([]models.Teacher, int, error) {
-	db, err := ConnectDB()
+func GetTeachersDBHandler(opts repository.ListOptions) ([]models.Teacher, int, error) {
+	db, err := getDB()
 	if err != nil {
 		return []models.Teacher{}, 0, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
-	rows, err := db.Query(query, args...)
+	where, args := buildWhereClause(opts)
+	cursorClause, cursorArgs := buildCursorClause(opts, len(args))
+	query := "SELECT id, first_name, last_name, email, class, subject, created_at FROM teachers WHERE 1=1" + where + cursorClause + buildPageClause(opts)
+
+	rows, err := db.Query(query, append(args, cursorArgs...)...)
 	if err != nil {
 		return []models.Teacher{}, 0, utils.ErrorHandler(err, "Error fetching Teachers.")
 	}
@@ -28,30 +33,33 @@ func GetTeachersDBHandler(query string, args []interface{}) ([]models.Teacher, i
 	teacherList := make([]models.Teacher, 0)
 	for rows.Next() {
 		var teacher models.Teacher
-		err = rows.Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Class, &teacher.Subject)
+		err = rows.Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Class, &teacher.Subject, &teacher.CreatedAt)
 		if err != nil {
 			return []models.Teacher{}, 0, utils.ErrorHandler(err, "Error fetching Teachers.")
 		}
 		teacherList = append(teacherList, teacher)
 	}
+	err = rows.Err()
+	if err != nil {
+		return []models.Teacher{}, 0, utils.ErrorHandler(err, "Error fetching Teachers.")
+	}
+
+	// Count with the same filters, so the total matches the pages.
 	var totalTeachers int
-	err = db.QueryRow("SELECT COUNT(*) FROM teachers").Scan(&totalTeachers)
+	err = db.QueryRow("SELECT COUNT(*) FROM teachers WHERE 1=1"+where, args...).Scan(&totalTeachers)
 	if err != nil {
-		utils.ErrorHandler(err, "")
-		return teacherList, 0, nil
+		return []models.Teacher{}, 0, utils.ErrorHandler(err, "Error counting Teachers.")
 	}
 	return teacherList, totalTeachers, nil
 }
 
 func GetStudentsByTeachersIDDBHandler(teacherId int, students []models.Student) ([]models.Student, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return nil, utils.ErrorHandler(err, "Error connecting DB.")
 	}
 
-	defer db.Close()
-
-	query := "SELECT id, first_name, last_name, email, class FROM students WHERE class=(SELECT class FROM teachers WHERE id=$1)"
+	query := "SELECT id, first_name, last_name, email, class, created_at FROM students WHERE class=(SELECT class FROM teachers WHERE id=$1)"
 	rows, err := db.Query(query, teacherId)
 	if err != nil {
 		return nil, utils.ErrorHandler(err, "Error fetching Student Count.")
@@ -59,7 +67,7 @@ func GetStudentsByTeachersIDDBHandler(teacherId int, students []models.Student)
 	defer rows.Close()
 	for rows.Next() {
 		var student models.Student
-		err := rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.Class)
+		err := rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.Class, &student.CreatedAt)
 		if err != nil {
 			return nil, utils.ErrorHandler(err, "Error fetching Student Count.")
 		}
@@ -74,12 +82,10 @@ func GetStudentsByTeachersIDDBHandler(teacherId int, students []models.Student)
 }
 
 func GetStudentCountByTeacherIDDBHandler(teacherId int) (int, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return 0, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-
-	defer db.Close()
 	var studentCount int
 
 	query := "SELECT COUNT(*) FROM students WHERE class=(SELECT class FROM teachers WHERE id=$1)"
@@ -91,16 +97,15 @@ func GetStudentCountByTeacherIDDBHandler(teacherId int) (int, error) {
 }
 
 func GetOneTeacherDBHandler(teacherId int) (models.Teacher, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return models.Teacher{}, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	var tchr models.Teacher
-	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class, subject FROM teachers WHERE id = %d", teacherId)).Scan(&tchr.ID, &tchr.FirstName, &tchr.LastName, &tchr.Email, &tchr.Class, &tchr.Subject)
+	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class, subject, created_at FROM teachers WHERE id = %d", teacherId)).Scan(&tchr.ID, &tchr.FirstName, &tchr.LastName, &tchr.Email, &tchr.Class, &tchr.Subject, &tchr.CreatedAt)
 	if err == sql.ErrNoRows {
-		return models.Teacher{}, utils.ErrorHandler(err, fmt.Sprintf("Error fetching Teacher %d.", teacherId))
+		return models.Teacher{}, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", teacherId))
 	} else if err != nil {
 		return models.Teacher{}, utils.ErrorHandler(err, fmt.Sprintf("Error fetching Teacher %d.", teacherId))
 	}
@@ -108,11 +113,10 @@ func GetOneTeacherDBHandler(teacherId int) (models.Teacher, error) {
 }
 
 func PostTeachersDBHandler(newTeachers []models.Teacher) ([]models.Teacher, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return nil, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	// Prepare Query
 	tx, err := db.Begin()
@@ -130,12 +134,13 @@ func PostTeachersDBHandler(newTeachers []models.Teacher) ([]models.Teacher, erro
 
 	addedTeachers := make([]models.Teacher, len(newTeachers))
 	for i, newTeacher := range newTeachers {
+		newTeacher.CreatedAt = currentTimestamp()
 		values := getStructValues(newTeacher)
 		// _, err := stmt.Exec(newTeacher.FirstName, newTeacher.LastName, newTeacher.Email, newTeacher.Class, newTeacher.Subject)
 		_, err := stmt.Exec(values...)
 		if err != nil {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error Adding teachers.")
+			return nil, dbErrorHandler(err, "Error Adding teachers.")
 		}
 		addedTeachers[i] = newTeacher
 	}
@@ -148,38 +153,36 @@ func PostTeachersDBHandler(newTeachers []models.Teacher) ([]models.Teacher, erro
 }
 
 func PutOneTeacherDBHandler(teacherId int, updatedTchr models.Teacher) error {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	var existingTchr models.Teacher
-	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class, subject FROM teachers WHERE id = %d", teacherId)).Scan(&existingTchr.ID, &existingTchr.FirstName, &existingTchr.LastName, &existingTchr.Email, &existingTchr.Class, &existingTchr.Subject)
+	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class, subject, created_at FROM teachers WHERE id = %d", teacherId)).Scan(&existingTchr.ID, &existingTchr.FirstName, &existingTchr.LastName, &existingTchr.Email, &existingTchr.Class, &existingTchr.Subject, &existingTchr.CreatedAt)
 	if err == sql.ErrNoRows {
-		return utils.ErrorHandler(err, fmt.Sprintf("Error updating Teacher %d.", teacherId))
+		return utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", teacherId))
 	} else if err != nil {
 		return utils.ErrorHandler(err, fmt.Sprintf("Error updating Teacher %d.", teacherId))
 	}
 
 	_, err = db.Exec("UPDATE teachers SET first_name=$1, last_name=$2, email=$3, class=$4, subject=$5 WHERE id=$6", updatedTchr.FirstName, updatedTchr.LastName, updatedTchr.Email, updatedTchr.Class, updatedTchr.Subject, existingTchr.ID)
 	if err != nil {
-		return utils.ErrorHandler(err, fmt.Sprintf("Error updating Teacher %d.", teacherId))
+		return dbErrorHandler(err, fmt.Sprintf("Error updating Teacher %d.", teacherId))
 	}
 	return nil
 }
 
 func PatchOneTeacherDBHandler(teacherId int, updates map[string]interface{}) (models.Teacher, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return models.Teacher{}, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	var existingTchr models.Teacher
-	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class, subject FROM teachers WHERE id = %d", teacherId)).Scan(&existingTchr.ID, &existingTchr.FirstName, &existingTchr.LastName, &existingTchr.Email, &existingTchr.Class, &existingTchr.Subject)
+	err = db.QueryRow(fmt.Sprintf("SELECT id, first_name, last_name, email, class, subject, created_at FROM teachers WHERE id = %d", teacherId)).Scan(&existingTchr.ID, &existingTchr.FirstName, &existingTchr.LastName, &existingTchr.Email, &existingTchr.Class, &existingTchr.Subject, &existingTchr.CreatedAt)
 	if err == sql.ErrNoRows {
-		return models.Teacher{}, utils.ErrorHandler(err, fmt.Sprintf("Error updating Teacher %d.", teacherId))
+		return models.Teacher{}, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", teacherId))
 	} else if err != nil {
 		return models.Teacher{}, utils.ErrorHandler(err, fmt.Sprintf("Error updating Teacher %d.", teacherId))
 	}
@@ -195,6 +198,9 @@ func PatchOneTeacherDBHandler(teacherId int, updates map[string]interface{}) (mo
 
 			// Check whether such key exists in fields and set its value to v
 			if json_field == k+",omitempty" && teacherVal.Field(i).CanSet() {
+				if v == nil || !reflect.ValueOf(v).Type().ConvertibleTo(teacherVal.Field(i).Type()) {
+					return models.Teacher{}, utils.TypedErrorHandler(fmt.Errorf("cannot convert %T", v), utils.ErrValidation, fmt.Sprintf("Invalid value for field %s.", k))
+				}
 				teacherVal.Field(i).Set(reflect.ValueOf(v).Convert(teacherVal.Field(i).Type()))
 			}
 		}
@@ -202,17 +208,16 @@ func PatchOneTeacherDBHandler(teacherId int, updates map[string]interface{}) (mo
 
 	_, err = db.Exec("UPDATE teachers SET first_name=$1, last_name=$2, email=$3, class=$4, subject=$5 WHERE id=$6", existingTchr.FirstName, existingTchr.LastName, existingTchr.Email, existingTchr.Class, existingTchr.Subject, existingTchr.ID)
 	if err != nil {
-		return models.Teacher{}, utils.ErrorHandler(err, fmt.Sprintf("Error updating Teacher %d.", teacherId))
+		return models.Teacher{}, dbErrorHandler(err, fmt.Sprintf("Error updating Teacher %d.", teacherId))
 	}
 	return existingTchr, nil
 }
 
 func PatchTeachersDBHandler(updates []map[string]interface{}) ([]models.Teacher, error) {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return nil, utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -224,20 +229,20 @@ func PatchTeachersDBHandler(updates []map[string]interface{}) ([]models.Teacher,
 		tchrIdStr, ok := update["id"].(string)
 		if !ok {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Teachers.")
+			return nil, utils.TypedErrorHandler(errors.New("id should be a string"), utils.ErrValidation, "Invalid Teacher ID.")
 		}
 
 		tchrId, err := strconv.Atoi(tchrIdStr)
 		if err != nil {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Teachers.")
+			return nil, utils.TypedErrorHandler(err, utils.ErrValidation, "Invalid Teacher ID.")
 		}
 
 		var existingTchr models.Teacher
-		err = tx.QueryRow("SELECT id, first_name, last_name, email, class, subject FROM teachers WHERE id = $1", tchrId).Scan(&existingTchr.ID, &existingTchr.FirstName, &existingTchr.LastName, &existingTchr.Email, &existingTchr.Class, &existingTchr.Subject)
+		err = tx.QueryRow("SELECT id, first_name, last_name, email, class, subject, created_at FROM teachers WHERE id = $1", tchrId).Scan(&existingTchr.ID, &existingTchr.FirstName, &existingTchr.LastName, &existingTchr.Email, &existingTchr.Class, &existingTchr.Subject, &existingTchr.CreatedAt)
 		if err == sql.ErrNoRows {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Teachers.")
+			return nil, utils.TypedErrorHandler(err, utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", tchrId))
 		} else if err != nil {
 			tx.Rollback()
 			return nil, utils.ErrorHandler(err, "Error updating Teachers.")
@@ -257,11 +262,11 @@ func PatchTeachersDBHandler(updates []map[string]interface{}) ([]models.Teacher,
 
 				// Check whether such key exists in fields and set its value to v
 				if json_field == k+",omitempty" && teacherVal.Field(i).CanSet() {
-					if reflect.ValueOf(v).Type().ConvertibleTo(teacherVal.Field(i).Type()) {
+					if v != nil && reflect.ValueOf(v).Type().ConvertibleTo(teacherVal.Field(i).Type()) {
 						teacherVal.Field(i).Set(reflect.ValueOf(v).Convert(teacherVal.Field(i).Type()))
 					} else {
 						tx.Rollback()
-						return nil, utils.ErrorHandler(err, "Error updating Teachers.")
+						return nil, utils.TypedErrorHandler(fmt.Errorf("cannot convert %T", v), utils.ErrValidation, fmt.Sprintf("Invalid value for field %s.", k))
 					}
 					break
 				}
@@ -271,7 +276,7 @@ func PatchTeachersDBHandler(updates []map[string]interface{}) ([]models.Teacher,
 		_, err = tx.Exec("UPDATE teachers SET first_name=$1, last_name=$2, email=$3, class=$4, subject=$5 WHERE id=$6", existingTchr.FirstName, existingTchr.LastName, existingTchr.Email, existingTchr.Class, existingTchr.Subject, existingTchr.ID)
 		if err != nil {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "Error updating Teachers.")
+			return nil, dbErrorHandler(err, "Error updating Teachers.")
 		}
 		existingTchrs = append(existingTchrs, existingTchr)
 	}
@@ -284,12 +289,11 @@ func PatchTeachersDBHandler(updates []map[string]interface{}) ([]models.Teacher,
 }
 
 func DeleteOneTeacherDBHandler(teacherId int) error {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		// log.Println("Error connecting DB :", err)
 		return utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	// Perform the delete operation
 	res, err := db.Exec("DELETE FROM teachers WHERE id=$1", teacherId)
@@ -304,17 +308,16 @@ func DeleteOneTeacherDBHandler(teacherId int) error {
 
 	// Operation was successful, but no rows affected i.e. invalid ID.
 	if rowsAffected == 0 {
-		return utils.ErrorHandler(err, fmt.Sprintf("Error deleting Teacher %d.", teacherId))
+		return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", teacherId))
 	}
 	return nil
 }
 
 func DeleteTeachersDBHandler(ids []int) error {
-	db, err := ConnectDB()
+	db, err := getDB()
 	if err != nil {
 		return utils.ErrorHandler(err, "Error connecting DB.")
 	}
-	defer db.Close()
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -340,7 +343,7 @@ func DeleteTeachersDBHandler(ids []int) error {
 		// Operation was successful, but no rows affected i.e. invalid ID.
 		if rowsAffected == 0 {
 			tx.Rollback()
-			return utils.ErrorHandler(err, "Error deleting Teachers.")
+			return utils.TypedErrorHandler(sql.ErrNoRows, utils.ErrNotFound, fmt.Sprintf("Teacher %d not found.", teacherId))
 		}
 	}
 	err = tx.Commit()
@@ -1,14 +1,70 @@
 package utils
 
-import "errors"
-
 type ContextKey string
 
-func AuthorizeUser(usrRole string, allowedRoles ...string) (bool, error) {
-	for _, allowedRole := range allowedRoles {
-		if usrRole == allowedRole {
-			return true, nil
+// A permission is "<resource>:<action>". Routes declare the permission they need in the router
+// package, roles are granted permissions below.
+type Permission string
+
+const (
+	PermTeachersRead  Permission = "teachers:read"
+	PermTeachersWrite Permission = "teachers:write"
+	PermTeachersAdmin Permission = "teachers:admin"
+	PermStudentsRead  Permission = "students:read"
+	PermStudentsWrite Permission = "students:write"
+	PermStudentsAdmin Permission = "students:admin"
+	PermExecsRead     Permission = "execs:read"
+	PermExecsWrite    Permission = "execs:write"
+	PermExecsAdmin    Permission = "execs:admin"
+	PermExecsPassword Permission = "execs:password"
+	PermExecsSelf     Permission = "execs:self"
+	PermSearchRead    Permission = "search:read"
+	PermAPIKeysAdmin  Permission = "apikeys:admin"
+)
+
+// The single place roles are mapped to permissions. write covers updates, admin covers
+// deletes and whatever else is reserved for admins on that resource (e.g. creating students).
+// execs:write only reaches the exec's own record unless it also has execs:admin, execs:self
+// is the /execs/me routes. service is the role of API keys used by other services, each key
+// is further limited to its scopes.
+var rolePermissions = map[string][]Permission{
+	"admin": {
+		PermTeachersRead, PermTeachersWrite, PermTeachersAdmin,
+		PermStudentsRead, PermStudentsWrite, PermStudentsAdmin,
+		PermExecsRead, PermExecsWrite, PermExecsAdmin, PermExecsPassword, PermExecsSelf,
+		PermSearchRead, PermAPIKeysAdmin,
+	},
+	"exec": {
+		PermTeachersRead, PermTeachersWrite,
+		PermStudentsRead, PermStudentsWrite,
+		PermExecsRead, PermExecsWrite, PermExecsPassword, PermExecsSelf,
+		PermSearchRead,
+	},
+	"manager": {
+		PermStudentsRead,
+		PermExecsPassword, PermExecsSelf,
+	},
+	"service": {
+		PermTeachersRead, PermTeachersWrite,
+		PermStudentsRead, PermStudentsWrite,
+		PermSearchRead,
+	},
+}
+
+// Reports whether role was granted permission. Unknown roles have no permissions.
+func HasPermission(role string, permission Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
 		}
 	}
-	return false, errors.New("user not authorized")
-}
\ No newline at end of file
+	return false
+}
+
+// Returns a Forbidden error unless role was granted permission.
+func AuthorizeUser(role string, permission Permission) error {
+	if !HasPermission(role, permission) {
+		return &AppError{Kind: ErrForbidden, Msg: "user not authorized"}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Deepest nesting accepted, WebAuthn structures are at most a few levels deep.
+const maxCBORDepth = 16
+
+// Decodes the CBOR data item at the start of data, returning it and the number of bytes it took.
+// Covers what WebAuthn uses: integers (as int64), byte strings ([]byte), text strings, arrays,
+// maps (map[interface{}]interface{}), tags (dropped) and simple values, with definite lengths only.
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, int, error) {
+	if depth > maxCBORDepth {
+		return nil, 0, errors.New("cbor: nested too deeply")
+	}
+	if len(data) == 0 {
+		return nil, 0, errors.New("cbor: unexpected end of data")
+	}
+	major := data[0] >> 5
+	arg, n, err := cborArgument(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("cbor: integer overflows int64")
+		}
+		return int64(arg), n, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("cbor: integer overflows int64")
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3:
+		if arg > uint64(len(data)-n) {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		end := n + int(arg)
+		if major == 3 {
+			return string(data[n:end]), end, nil
+		}
+		return append([]byte{}, data[n:end]...), end, nil
+	case 4:
+		// Every item takes at least a byte, which bounds the allocation.
+		if arg > uint64(len(data)-n) {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, size, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+			n += size
+		}
+		return items, n, nil
+	case 5:
+		if arg > uint64(len(data)-n)/2 {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		items := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, size, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += size
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, 0, fmt.Errorf("cbor: unsupported map key type %T", key)
+			}
+			if _, ok := items[key]; ok {
+				return nil, 0, fmt.Errorf("cbor: duplicate map key %v", key)
+			}
+			value, size, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items[key] = value
+			n += size
+		}
+		return items, n, nil
+	case 6:
+		item, size, err := decodeCBORItem(data[n:], depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return item, n + size, nil
+	}
+
+	// Major type 7, simple values and floats.
+	switch info := data[0] & 0x1f; {
+	case info == 20:
+		return false, n, nil
+	case info == 21:
+		return true, n, nil
+	case info == 22, info == 23:
+		return nil, n, nil
+	case info == 26:
+		return float64(math.Float32frombits(uint32(arg))), n, nil
+	case info == 27:
+		return math.Float64frombits(arg), n, nil
+	}
+	return nil, 0, fmt.Errorf("cbor: unsupported simple value 0x%02x", data[0])
+}
+
+// Reads the argument of the item header at the start of data: the value of integers, the
+// length of strings, arrays and maps. Also returns the size of the header.
+func cborArgument(data []byte) (uint64, int, error) {
+	info := data[0] & 0x1f
+	size := map[byte]int{24: 1, 25: 2, 26: 4, 27: 8}[info]
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case size == 0:
+		return 0, 0, errors.New("cbor: indefinite lengths are not supported")
+	case len(data) < 1+size:
+		return 0, 0, errors.New("cbor: unexpected end of data")
+	}
+
+	buf := make([]byte, 8)
+	copy(buf[8-size:], data[1:1+size])
+	return binary.BigEndian.Uint64(buf), 1 + size, nil
+}
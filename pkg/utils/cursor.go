@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Content of a pagination cursor. Clients only ever see the opaque base64 form.
+type cursorPayload struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+	ID     int      `json:"id"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Encodes the sort values and id of the last row of a page into an opaque cursor.
+// sortKey identifies the sortby the cursor was issued for.
+func EncodeCursor(sortKey string, values []string, id int) string {
+	payload, _ := json.Marshal(cursorPayload{Sort: sortKey, Values: values, ID: id})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// Decodes a cursor from EncodeCursor. Cursors issued for another sortby are rejected,
+// as their values would be compared against the wrong columns.
+func DecodeCursor(cursor string, sortKey string, sortCount int) ([]string, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, errInvalidCursor
+	}
+	var payload cursorPayload
+	err = json.Unmarshal(raw, &payload)
+	if err != nil || payload.Sort != sortKey || len(payload.Values) != sortCount || payload.ID < 1 {
+		return nil, 0, errInvalidCursor
+	}
+	return payload.Values, payload.ID, nil
+}
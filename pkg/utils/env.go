@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Reads an int setting from key. Unset or malformed values, and values below min, give fallback.
+func EnvInt(key string, fallback int, min int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < min {
+		return fallback
+	}
+	return value
+}
+
+// Reads a duration setting like "15m" from key, with the same fallback rules as EnvInt.
+func EnvDuration(key string, fallback time.Duration, min time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value < min {
+		return fallback
+	}
+	return value
+}
+
+func envBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -1,13 +1,63 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 )
 
+// Kinds of failures the handlers translate into HTTP status codes.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrValidation   = errors.New("validation failed")
+)
+
+// AppError carries the message shown to the client along with the kind of failure.
+type AppError struct {
+	Kind error
+	Msg  string
+}
+
+func (e *AppError) Error() string {
+	return e.Msg
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Kind
+}
+
 func ErrorHandler(err error, msg string) error {
 	errorLogger := log.New(os.Stderr, "ERROR : ", log.Ldate|log.Ltime|log.Lshortfile)
 	errorLogger.Println(msg, err)
 	return fmt.Errorf("%s", msg)
-}
\ No newline at end of file
+}
+
+// Same as ErrorHandler, but the returned error is tagged with kind (ErrNotFound, ErrConflict, ...).
+func TypedErrorHandler(err error, kind error, msg string) error {
+	errorLogger := log.New(os.Stderr, "ERROR : ", log.Ldate|log.Ltime|log.Lshortfile)
+	errorLogger.Println(msg, err)
+	return &AppError{Kind: kind, Msg: msg}
+}
+
+// Maps an error returned by the repository / utils layers to its HTTP status code.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrValidation):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
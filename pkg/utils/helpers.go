@@ -1,22 +1,17 @@
 package utils
 
 import (
-	"errors"
+	"database/sql"
+	"fmt"
 	"net/http"
 	"reflect"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
-func CheckBlankFields(model interface{}) error {
-	val := reflect.ValueOf(model)
-	for i := 0; i < val.NumField(); i++ {
-		if val.Field(i).Kind() == reflect.String && val.Field(i).String() == "" {
-			return ErrorHandler(errors.New("all fields are required"), "All Fields are required.")
-		}
-	}
-	return nil
-}
+// Upper bound for the limit query param, so one request can't dump a whole table.
+const MaxPageLimit = 100
 
 func GetFieldNames(model interface{}) []string {
 	val := reflect.TypeOf(model)
@@ -27,6 +22,24 @@ func GetFieldNames(model interface{}) []string {
 	return fields
 }
 
+// Returns the value of the field tagged db:"column" as a string, "" if there is none.
+func GetColumnValue(model interface{}, column string) string {
+	modelValue := reflect.ValueOf(model)
+	modelType := modelValue.Type()
+	for i := 0; i < modelType.NumField(); i++ {
+		dbTag := strings.TrimSuffix(modelType.Field(i).Tag.Get("db"), ",omitempty")
+		if dbTag != column {
+			continue
+		}
+		value := modelValue.Field(i).Interface()
+		if nullString, ok := value.(sql.NullString); ok {
+			return nullString.String
+		}
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
 func GetPaginationParams(r *http.Request) (int ,int){
 	page, err := strconv.Atoi(r.URL.Query().Get("page"))
 	if err != nil || page < 1 {
@@ -36,5 +49,84 @@ func GetPaginationParams(r *http.Request) (int ,int){
 	if err != nil || limit < 10 {
 		limit = 10
 	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
 	return page, limit
+}
+
+// Most terms taken from a search query, the rest are ignored.
+const MaxSearchTerms = 10
+
+// Splits a search query into lower case terms of letters and digits, e.g. "O'Brien 9a" gives
+// [o brien 9a]. Everything else is dropped, which keeps the terms safe inside a tsquery.
+func SearchTerms(query string) []string {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) > MaxSearchTerms {
+		terms = terms[:MaxSearchTerms]
+	}
+	return terms
+}
+
+// Pagination metadata of a list response, embedded in the response envelope.
+type PageInfo struct {
+	TotalPages int    `json:"total_pages"`
+	HasNext    bool   `json:"has_next"`
+	HasPrev    bool   `json:"has_prev"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Builds the pagination metadata for a page of a list of total items.
+// Links keep the query params of the request, only page and limit are replaced.
+// nextCursor lets a client switch to cursor pagination from any page.
+func GetPageInfo(r *http.Request, page, limit, total int, nextCursor string) PageInfo {
+	totalPages := (total + limit - 1) / limit
+	info := PageInfo{
+		TotalPages: totalPages,
+		HasNext:    page < totalPages,
+		HasPrev:    page > 1,
+		NextCursor: nextCursor,
+	}
+	if info.HasNext {
+		info.Next = pageLink(r, page+1, limit)
+	}
+	if info.HasPrev {
+		// A page past the end links back to the last page.
+		info.Prev = pageLink(r, min(page-1, max(totalPages, 1)), limit)
+	}
+	return info
+}
+
+// Builds the pagination metadata for a page fetched with ?cursor=. Cursors only go forward,
+// so there is no prev link. nextCursor is "" on the last page.
+func GetCursorPageInfo(r *http.Request, limit int, hasPrev bool, total int, nextCursor string) PageInfo {
+	info := PageInfo{
+		TotalPages: (total + limit - 1) / limit,
+		HasNext:    nextCursor != "",
+		HasPrev:    hasPrev,
+		NextCursor: nextCursor,
+	}
+	if info.HasNext {
+		link := *r.URL
+		query := link.Query()
+		query.Del("page")
+		query.Set("cursor", nextCursor)
+		query.Set("limit", strconv.Itoa(limit))
+		link.RawQuery = query.Encode()
+		info.Next = link.RequestURI()
+	}
+	return info
+}
+
+func pageLink(r *http.Request, page, limit int) string {
+	link := *r.URL
+	query := link.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(limit))
+	link.RawQuery = query.Encode()
+	return link.RequestURI()
 }
\ No newline at end of file
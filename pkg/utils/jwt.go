@@ -1,35 +1,156 @@
 package utils
 
 import (
+	"errors"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func SignToken(userId int, username, role string) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	jwtExpiresIn := os.Getenv("JWT_EXPIRES")
-	
+// Lifetime of access tokens, JWT_EXPIRES or 15 minutes. The Bearer cookie expires with the token.
+func AccessTokenDuration() (time.Duration, error) {
+	return envTokenDuration("JWT_EXPIRES", 15*time.Minute)
+}
+
+// Lifetime of refresh tokens, REFRESH_TOKEN_EXPIRES or 7 days.
+func RefreshTokenDuration() (time.Duration, error) {
+	return envTokenDuration("REFRESH_TOKEN_EXPIRES", 7*24*time.Hour)
+}
+
+// aud claims of the tokens signed with the published keys. Verifiers of login tokens, this
+// server or others using /.well-known/jwks.json, require AccessTokenAudience.
+const (
+	AccessTokenAudience = "rest-go"
+	MFATokenAudience    = "rest-go:mfa"
+)
+
+func envTokenDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func SignToken(userId int, username, role string, sessionId int) (string, error) {
+	duration, err := AccessTokenDuration()
+	if err != nil {
+		return "", err
+	}
+
+	jti, err := GenerateTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	// jti lets a single token be revoked, iat is checked against password_changed_at,
+	// sid ties the token to the login session it was issued for.
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"uid": userId,
 		"user": username,
 		"role": role,
+		"jti": jti,
+		"sid": sessionId,
+		"aud": AccessTokenAudience,
+		"iat": jwt.NewNumericDate(now),
+		"exp": jwt.NewNumericDate(now.Add(duration)),
+	}
+	signedToken, err := signClaims(claims)
+	if err != nil {
+		return "", err
+	}
+	return signedToken, nil
+}
+
+// Claims of a login token, as used by LogoutExecHandler.
+type AccessTokenClaims struct {
+	ID        string
+	UserID    int
+	SessionID int
+	ExpiresAt time.Time
+}
+
+// Verifies a login token outside of JWT_MW. Only the signature and exp are checked, not the
+// server side state JWT_MW checks.
+func ParseAccessToken(tokenString string) (AccessTokenClaims, error) {
+	parsedToken, err := ParseToken(tokenString, AccessTokenAudience)
+	if err != nil {
+		return AccessTokenClaims{}, err
+	}
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || !parsedToken.Valid {
+		return AccessTokenClaims{}, errors.New("invalid login token")
 	}
+	jti, _ := claims["jti"].(string)
+	userId, _ := claims["uid"].(float64)
+	sessionId, _ := claims["sid"].(float64)
+	expiresAt, err := claims.GetExpirationTime()
+	_, hasPurpose := claims["purpose"]
+	if jti == "" || userId == 0 || err != nil || expiresAt == nil || hasPurpose {
+		return AccessTokenClaims{}, errors.New("invalid login token")
+	}
+	return AccessTokenClaims{ID: jti, UserID: int(userId), SessionID: int(sessionId), ExpiresAt: expiresAt.Time}, nil
+}
+
+// Lifetime of the token between the password and the MFA step, MFA_TOKEN_EXPIRES or 5 minutes.
+func MFATokenDuration() (time.Duration, error) {
+	return envTokenDuration("MFA_TOKEN_EXPIRES", 5*time.Minute)
+}
 
-	if jwtExpiresIn != "" {
-		duration, err := time.ParseDuration(jwtExpiresIn)
-		if err != nil {
-			return "", err
-		}
-		claims["exp"] = jwt.NewNumericDate(time.Now().Add(duration))
-	} else {
-		claims["exp"] = jwt.NewNumericDate(time.Now().Add(15*time.Minute))
+// Signs the "mfa pending" token returned by login when a second factor is needed. It only
+// carries the exec id and the mfa purpose, JWT_MW refuses it as a login token. The jti makes
+// it single use, LoginMFAHandler uses it up once the second factor is verified.
+func SignMFAToken(userId int) (string, error) {
+	duration, err := MFATokenDuration()
+	if err != nil {
+		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(jwtSecret))
+
+	jti, err := GenerateTokenID()
 	if err != nil {
 		return "", err
 	}
-	return signedToken, nil
-}
\ No newline at end of file
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"uid": userId,
+		"jti": jti,
+		"purpose": "mfa",
+		"aud": MFATokenAudience,
+		"iat": jwt.NewNumericDate(now),
+		"exp": jwt.NewNumericDate(now.Add(duration)),
+	}
+	return signClaims(claims)
+}
+
+// Claims of an "mfa pending" token.
+type MFATokenClaims struct {
+	ID        string
+	UserID    int
+	ExpiresAt time.Time
+}
+
+// Verifies an "mfa pending" token and returns the exec id it was issued for, with its jti.
+func ParseMFAToken(tokenString string) (MFATokenClaims, error) {
+	parsedToken, err := ParseToken(tokenString, MFATokenAudience)
+	if err != nil {
+		return MFATokenClaims{}, &AppError{Kind: ErrUnauthorized, Msg: "Invalid or expired MFA token, please log in again."}
+	}
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != "mfa" {
+		return MFATokenClaims{}, &AppError{Kind: ErrUnauthorized, Msg: "Invalid MFA token."}
+	}
+	userId, ok := claims["uid"].(float64)
+	if !ok {
+		return MFATokenClaims{}, ErrorHandler(errors.New("mfa token without uid"), "Invalid MFA token.")
+	}
+	// Tokens signed before they had a jti can't be used up, they are refused.
+	jti, _ := claims["jti"].(string)
+	expiresAt, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || expiresAt == nil {
+		return MFATokenClaims{}, &AppError{Kind: ErrUnauthorized, Msg: "Invalid MFA token, please log in again."}
+	}
+	return MFATokenClaims{ID: jti, UserID: int(userId), ExpiresAt: expiresAt.Time}, nil
+}
@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms accepted in JWT_ALGORITHM.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// One key of the set. private is nil for keys that only verify, e.g. a retired signing key.
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// Keys used to sign and verify login tokens.
+//
+// With JWT_ALGORITHM=HS256 (the default) tokens are signed with JWT_SECRET and carry no kid.
+// With RS256 or EdDSA every <kid>.pem file in JWT_KEYS_DIR is loaded, private keys can sign and
+// verify, public keys only verify. JWT_SIGNING_KID picks the signing key, it can be left out
+// when the directory holds a single private key. All keys are published at /.well-known/jwks.json.
+//
+// Rotating a key:
+//  1. Add the new private key to JWT_KEYS_DIR and restart, verifiers now see it in the JWKS.
+//  2. Point JWT_SIGNING_KID at the new kid and restart, new tokens are signed with it.
+//  3. Replace the old private key with its public key, tokens it signed still verify.
+//  4. Once JWT_EXPIRES has passed, delete the old public key.
+type JWTKeySet struct {
+	signing jwtKey
+	keys    map[string]jwtKey
+}
+
+var jwtKeys *JWTKeySet
+
+// Loads the key set from the environment, called once at startup.
+func LoadJWTKeys() error {
+	algorithm := os.Getenv("JWT_ALGORITHM")
+	if algorithm == "" || algorithm == AlgHS256 {
+		if os.Getenv("JWT_SECRET") == "" {
+			return errors.New("JWT_SECRET must be set for HS256")
+		}
+		jwtKeys = nil
+		return nil
+	}
+	if algorithm != AlgRS256 && algorithm != AlgEdDSA {
+		return fmt.Errorf("unsupported JWT_ALGORITHM %q", algorithm)
+	}
+
+	keySet, err := loadKeyDir(os.Getenv("JWT_KEYS_DIR"))
+	if err != nil {
+		return err
+	}
+
+	signingKid := os.Getenv("JWT_SIGNING_KID")
+	if signingKid == "" {
+		for _, key := range keySet.keys {
+			if key.private == nil {
+				continue
+			}
+			if signingKid != "" {
+				return errors.New("JWT_SIGNING_KID must be set when JWT_KEYS_DIR holds several private keys")
+			}
+			signingKid = key.kid
+		}
+	}
+	signing, ok := keySet.keys[signingKid]
+	if !ok || signing.private == nil {
+		return fmt.Errorf("no private key found for kid %q", signingKid)
+	}
+	if signing.method.Alg() != algorithm {
+		return fmt.Errorf("signing key %q can't sign %s tokens", signingKid, algorithm)
+	}
+	keySet.signing = signing
+	jwtKeys = keySet
+	return nil
+}
+
+func loadKeyDir(dir string) (*JWTKeySet, error) {
+	if dir == "" {
+		return nil, errors.New("JWT_KEYS_DIR must be set for asymmetric signing")
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keySet := &JWTKeySet{keys: make(map[string]jwtKey)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		key.kid = strings.TrimSuffix(filepath.Base(file), ".pem")
+		keySet.keys[key.kid] = key
+	}
+	if len(keySet.keys) == 0 {
+		return nil, fmt.Errorf("no keys found in %s", dir)
+	}
+	return keySet, nil
+}
+
+// Accepts PKCS#1/PKCS#8 RSA keys and PKCS#8 Ed25519 keys, private or public.
+func parseKeyPEM(data []byte) (jwtKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return jwtKey{}, errors.New("not a PEM file")
+	}
+
+	if strings.Contains(block.Type, "PRIVATE KEY") {
+		if key, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			return jwtKey{method: jwt.SigningMethodRS256, private: key, public: &key.PublicKey}, nil
+		}
+		if key, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+			edKey := key.(ed25519.PrivateKey)
+			return jwtKey{method: jwt.SigningMethodEdDSA, private: edKey, public: edKey.Public()}, nil
+		}
+		return jwtKey{}, errors.New("unsupported private key, expected RSA or Ed25519")
+	}
+
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return jwtKey{method: jwt.SigningMethodRS256, public: key}, nil
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return jwtKey{method: jwt.SigningMethodEdDSA, public: key}, nil
+	}
+	return jwtKey{}, errors.New("unsupported public key, expected RSA or Ed25519")
+}
+
+// Signs claims with the current signing key, HS256 with JWT_SECRET when no key set is loaded.
+func signClaims(claims jwt.Claims) (string, error) {
+	if jwtKeys == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("JWT_SECRET")))
+	}
+	token := jwt.NewWithClaims(jwtKeys.signing.method, claims)
+	token.Header["kid"] = jwtKeys.signing.kid
+	return token.SignedString(jwtKeys.signing.private)
+}
+
+// Parses and verifies a token against the key set, picking the key by kid. The algorithm
+// must match the key, so an RS256 key can never be used as an HMAC secret. The aud claim
+// must contain audience, so an "mfa pending" token is never accepted as a login token.
+func ParseToken(tokenString string, audience string) (*jwt.Token, error) {
+	if jwtKeys == nil {
+		return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			return []byte(os.Getenv("JWT_SECRET")), nil
+		}, jwt.WithValidMethods([]string{AlgHS256}), jwt.WithAudience(audience))
+	}
+
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := jwtKeys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method : %v", token.Header["alg"])
+		}
+		return key.public, nil
+	}, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}), jwt.WithAudience(audience))
+}
+
+// A public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// Public keys of the key set sorted by kid. Empty for HS256, the secret is never published.
+func JWKS() []JWK {
+	keys := []JWK{}
+	if jwtKeys == nil {
+		return keys
+	}
+
+	for _, key := range jwtKeys.keys {
+		jwk := JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		keys = append(keys, jwk)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Kid < keys[j].Kid
+	})
+	return keys
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"time"
+)
+
+// Failed login policy. From BackoffAfter consecutive failures on, each attempt has to wait
+// twice as long as the previous one, up to MaxBackoff. MaxAttempts failures lock for
+// LockoutDuration, doubled for every failure after that, up to MaxLockout.
+type LoginPolicy struct {
+	BackoffAfter    int
+	MaxBackoff      time.Duration
+	MaxAttempts     int
+	LockoutDuration time.Duration
+	MaxLockout      time.Duration
+}
+
+// Policy per username. LOGIN_MAX_ATTEMPTS (10) and LOGIN_LOCKOUT_DURATION (15m) are configurable.
+func UsernameLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		BackoffAfter:    3,
+		MaxBackoff:      time.Minute,
+		MaxAttempts:     EnvInt("LOGIN_MAX_ATTEMPTS", 10, 1),
+		LockoutDuration: EnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute, time.Second),
+		MaxLockout:      24 * time.Hour,
+	}
+}
+
+// Policy per client IP, looser since several execs may share an address.
+// LOGIN_MAX_ATTEMPTS_PER_IP (50) is configurable.
+func IPLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		BackoffAfter:    10,
+		MaxBackoff:      time.Minute,
+		MaxAttempts:     EnvInt("LOGIN_MAX_ATTEMPTS_PER_IP", 50, 1),
+		LockoutDuration: EnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute, time.Second),
+		MaxLockout:      24 * time.Hour,
+	}
+}
+
+// Magic login links sent to one email address are limited to MAGIC_LINK_MAX_PER_EMAIL (3)
+// per MAGIC_LINK_WINDOW (15m), so the endpoint can't be used to flood an inbox.
+type SendLimit struct {
+	Max    int
+	Window time.Duration
+}
+
+func MagicLinkSendLimit() SendLimit {
+	return SendLimit{
+		Max:    EnvInt("MAGIC_LINK_MAX_PER_EMAIL", 3, 1),
+		Window: EnvDuration("MAGIC_LINK_WINDOW", 15*time.Minute, time.Second),
+	}
+}
+
+// Wait required after the given number of consecutive failures, before the next attempt.
+func (p LoginPolicy) Backoff(failures int) time.Duration {
+	if failures < p.BackoffAfter {
+		return 0
+	}
+	return doubled(time.Second, failures-p.BackoffAfter, p.MaxBackoff)
+}
+
+// Lockout started by the given number of consecutive failures, 0 below MaxAttempts.
+func (p LoginPolicy) Lockout(failures int) time.Duration {
+	if failures < p.MaxAttempts {
+		return 0
+	}
+	return doubled(p.LockoutDuration, failures-p.MaxAttempts, p.MaxLockout)
+}
+
+func doubled(base time.Duration, times int, max time.Duration) time.Duration {
+	duration := base
+	for i := 0; i < times && duration < max; i++ {
+		duration *= 2
+	}
+	return min(duration, max)
+}
@@ -6,53 +6,146 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"golang.org/x/crypto/argon2"
 )
 
-func VerifyPassword(execPassword string, reqPassword string) error {
-	parts := strings.Split(execPassword, ".")
-	if len(parts) != 2 {
-		return ErrorHandler(errors.New("invalid encoded hash format"), "Internal Server error.")
+// Cost of argon2id. Hashes are stored in the PHC string format, which records the parameters,
+// e.g. $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>, so the cost can be raised without breaking
+// stored passwords: older hashes still verify and are rehashed on the next login.
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Parameters of the "salt.hash" format used before PHC strings, fixed since they weren't stored.
+var legacyArgon2Params = Argon2Params{Memory: 64 * 1024, Iterations: 1, Parallelism: 4, SaltLength: 16, KeyLength: 32}
+
+// Parameters for new hashes. ARGON2_MEMORY (KiB), ARGON2_ITERATIONS and ARGON2_PARALLELISM
+// override the defaults of 64 MiB, 3 iterations and 4 threads.
+func CurrentArgon2Params() Argon2Params {
+	return Argon2Params{
+		Memory:      uint32(EnvInt("ARGON2_MEMORY", 64*1024, 1)),
+		Iterations:  uint32(EnvInt("ARGON2_ITERATIONS", 3, 1)),
+		Parallelism: uint8(min(EnvInt("ARGON2_PARALLELISM", 4, 1), 255)),
+		SaltLength:  16,
+		KeyLength:   32,
 	}
-	hashedSaltBase64 := parts[0]
-	hashedPwdBase64 := parts[1]
+}
 
-	salt, err := base64.StdEncoding.DecodeString(hashedSaltBase64)
-	if err != nil {
-		return ErrorHandler(err, "Internal Server error.")
+func VerifyPassword(execPassword string, reqPassword string) error {
+	// Invited execs have no password until they accept the invitation.
+	if execPassword == "" {
+		return TypedErrorHandler(errors.New("no password set"), ErrUnauthorized, "Incorrect Username / Password.")
 	}
-	hashedPwd, err := base64.StdEncoding.DecodeString(hashedPwdBase64)
+	params, salt, hashedPwd, err := decodePasswordHash(execPassword)
 	if err != nil {
 		return ErrorHandler(err, "Internal Server error.")
 	}
 
-	hash := argon2.IDKey([]byte(reqPassword), salt, 1, 64*1024, 4, 32)
-	if len(hash) != len(hashedPwd) {
-		return ErrorHandler(err, "Incorrect Username / Password.")
-	}
+	hash := argon2.IDKey([]byte(reqPassword), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(hashedPwd)))
 	if subtle.ConstantTimeCompare(hash, hashedPwd) != 1 {
-		return ErrorHandler(err, "Incorrect Username / Password.")
+		return TypedErrorHandler(errors.New("password mismatch"), ErrUnauthorized, "Incorrect Username / Password.")
 	}
 	return nil
 }
 
 func HashPassword(newExecPassword string) (string, error) {
 	if newExecPassword == "" {
-		return "", ErrorHandler(errors.New("password is blank"), "Password cannot be Empty")
+		return "", TypedErrorHandler(errors.New("password is blank"), ErrValidation, "Password cannot be Empty")
 	}
-	salt := make([]byte, 16)
+	params := CurrentArgon2Params()
+	salt := make([]byte, params.SaltLength)
 	_, err := rand.Read(salt)
 	if err != nil {
 		return "", ErrorHandler(errors.New("failed to generate salt"), "Error adding Execs.")
 	}
 
 	// Hash the Password
-	hash := argon2.IDKey([]byte(newExecPassword), salt, 1, 64*1024, 4, 32)
-	saltBase64 := base64.StdEncoding.EncodeToString(salt)
-	hashBase64 := base64.StdEncoding.EncodeToString(hash)
-	encodedHash := fmt.Sprintf("%s.%s", saltBase64, hashBase64)
-	newExecPassword = encodedHash
+	hash := argon2.IDKey([]byte(newExecPassword), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	encodedHash := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash))
 	return encodedHash, nil
-}
\ No newline at end of file
+}
+
+// Reports whether a stored hash was made in the legacy format or with other parameters than
+// CurrentArgon2Params, and should be replaced once the password is known.
+func PasswordNeedsRehash(execPassword string) bool {
+	params, salt, hashedPwd, err := decodePasswordHash(execPassword)
+	if err != nil || !strings.HasPrefix(execPassword, "$argon2id$") {
+		return true
+	}
+	current := CurrentArgon2Params()
+	return params.Memory != current.Memory || params.Iterations != current.Iterations || params.Parallelism != current.Parallelism ||
+		uint32(len(salt)) != current.SaltLength || uint32(len(hashedPwd)) != current.KeyLength
+}
+
+// Splits a stored hash, in the PHC or the legacy format, into its parameters, salt and hash.
+func decodePasswordHash(encodedHash string) (Argon2Params, []byte, []byte, error) {
+	if !strings.HasPrefix(encodedHash, "$") {
+		parts := strings.Split(encodedHash, ".")
+		if len(parts) != 2 {
+			return Argon2Params{}, nil, nil, errors.New("invalid encoded hash format")
+		}
+		salt, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return Argon2Params{}, nil, nil, err
+		}
+		hash, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return Argon2Params{}, nil, nil, err
+		}
+		return legacyArgon2Params, salt, hash, nil
+	}
+
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, errors.New("unsupported password hash algorithm")
+	}
+	if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return Argon2Params{}, nil, nil, errors.New("unsupported argon2 version")
+	}
+
+	var params Argon2Params
+	for _, param := range strings.Split(parts[3], ",") {
+		key, value, _ := strings.Cut(param, "=")
+		number, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || number == 0 {
+			return Argon2Params{}, nil, nil, fmt.Errorf("invalid argon2 parameter %q", param)
+		}
+		switch key {
+		case "m":
+			params.Memory = uint32(number)
+		case "t":
+			params.Iterations = uint32(number)
+		case "p":
+			if number > 255 {
+				return Argon2Params{}, nil, nil, fmt.Errorf("invalid argon2 parameter %q", param)
+			}
+			params.Parallelism = uint8(number)
+		default:
+			return Argon2Params{}, nil, nil, fmt.Errorf("unknown argon2 parameter %q", param)
+		}
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return Argon2Params{}, nil, nil, errors.New("missing argon2 parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return Argon2Params{}, nil, nil, errors.New("invalid argon2 hash")
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(hash))
+	return params, salt, hash, nil
+}
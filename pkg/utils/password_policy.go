@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules a new password has to follow, checked when execs are added and on every password change.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Number of recent passwords, the current one included, that can't be reused.
+	History int
+}
+
+// Reads the policy from PASSWORD_MIN_LENGTH (default 12), PASSWORD_MAX_LENGTH (128),
+// PASSWORD_REQUIRE_UPPER, PASSWORD_REQUIRE_LOWER, PASSWORD_REQUIRE_DIGIT (true),
+// PASSWORD_REQUIRE_SYMBOL (false) and PASSWORD_HISTORY (5).
+func CurrentPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     EnvInt("PASSWORD_MIN_LENGTH", 12, 1),
+		MaxLength:     EnvInt("PASSWORD_MAX_LENGTH", 128, 1),
+		RequireUpper:  envBool("PASSWORD_REQUIRE_UPPER", true),
+		RequireLower:  envBool("PASSWORD_REQUIRE_LOWER", true),
+		RequireDigit:  envBool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol: envBool("PASSWORD_REQUIRE_SYMBOL", false),
+		History:       EnvInt("PASSWORD_HISTORY", 5, 1),
+	}
+}
+
+// Checks password against the policy and the breached password list. Every broken rule is
+// reported as an error on field, tagged with index for bulk requests. username and email are
+// those of the exec the password is for, it must not contain either of them.
+func (p PasswordPolicy) Validate(field string, index *int, password string, username string, email string) ValidationErrors {
+	var errs ValidationErrors
+	addError := func(message string) {
+		errs = append(errs, FieldError{Index: index, Field: field, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		addError(fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if length > p.MaxLength {
+		addError(fmt.Sprintf("must be at most %d characters", p.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			hasUpper = true
+		case unicode.IsLower(char):
+			hasLower = true
+		case unicode.IsDigit(char):
+			hasDigit = true
+		case !unicode.IsLetter(char) && !unicode.IsSpace(char):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		addError("must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		addError("must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		addError("must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		addError("must contain a symbol")
+	}
+
+	// Very short names would reject too many passwords, e.g. "al".
+	lowered := strings.ToLower(password)
+	if len(username) >= 3 && strings.Contains(lowered, strings.ToLower(username)) {
+		addError("must not contain the username")
+	}
+	localPart, _, _ := strings.Cut(email, "@")
+	if len(localPart) >= 3 && strings.Contains(lowered, strings.ToLower(localPart)) {
+		addError("must not contain the email address")
+	}
+
+	if IsBreachedPassword(password) {
+		addError("is a commonly used password found in data breaches")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Error for a password matching one of the History most recent ones.
+func (p PasswordPolicy) ReuseError(field string) ValidationErrors {
+	return ValidationErrors{{Field: field, Message: fmt.Sprintf("must not be one of your last %d passwords", p.History)}}
+}
+
+// Offline list of known breached passwords, shipped with the binary.
+//
+//go:embed data/breached_passwords.txt
+var defaultBreachedPasswords []byte
+
+var (
+	breachedPasswordsOnce sync.Once
+	breachedPasswords     map[string]struct{}
+)
+
+// Reports whether password is on the breached password list, ignoring case. The embedded
+// list is used unless BREACHED_PASSWORDS_FILE points to another one, loaded on first use.
+func IsBreachedPassword(password string) bool {
+	breachedPasswordsOnce.Do(func() {
+		list := defaultBreachedPasswords
+		if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("Couldn't read BREACHED_PASSWORDS_FILE, using the built-in list : %v", err)
+			} else {
+				list = data
+			}
+		}
+		breachedPasswords = parsePasswordList(list)
+	})
+	_, ok := breachedPasswords[strings.ToLower(password)]
+	return ok
+}
+
+// One password per line, blank lines and lines starting with # are skipped.
+func parsePasswordList(data []byte) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+	return passwords
+}
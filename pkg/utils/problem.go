@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Relative URI prefix for the problem "type" field, one per kind of failure.
+const ProblemTypeBaseURI = "/problems/"
+
+// RFC 7807 error body, sent as application/problem+json.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+var problemTypes = map[int]string{
+	http.StatusBadRequest:          "bad-request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not-found",
+	http.StatusMethodNotAllowed:    "method-not-allowed",
+	http.StatusConflict:            "conflict",
+	http.StatusLocked:              "locked",
+	http.StatusUnprocessableEntity: "validation-error",
+	http.StatusTooManyRequests:     "too-many-requests",
+	http.StatusInternalServerError: "internal-error",
+}
+
+func NewProblem(r *http.Request, status int, detail string) Problem {
+	problemType, ok := problemTypes[status]
+	if !ok {
+		problemType = "about:blank"
+	} else {
+		problemType = ProblemTypeBaseURI + problemType
+	}
+
+	return Problem{
+		Type:      problemType,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: GetRequestID(r),
+	}
+}
+
+// The single error response writer used by every handler and middleware.
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	WriteProblemJSON(w, NewProblem(r, status, detail))
+}
+
+// Writes err as a problem, with the status derived from its kind (see StatusCode).
+// Validation errors are listed field by field in the "errors" member.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(r, StatusCode(err), err.Error())
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		problem.Errors = validationErrs
+	}
+	WriteProblemJSON(w, problem)
+}
+
+func WriteProblemJSON(w http.ResponseWriter, problem Problem) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// Request id placed in the context by RequestIDMW.
+func GetRequestID(r *http.Request) string {
+	requestId, _ := r.Context().Value(ContextKey("requestId")).(string)
+	return requestId
+}
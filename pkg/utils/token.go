@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Generates a random hex token to send to the user, along with its sha256 hash to store.
+func GenerateHashedToken() (string, string, error) {
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	token := hex.EncodeToString(tokenBytes)
+	hashedToken := sha256.Sum256(tokenBytes)
+	return token, hex.EncodeToString(hashedToken[:]), nil
+}
+
+// Generates a random id for the jti claim, used to revoke a single access token.
+func GenerateTokenID() (string, error) {
+	idBytes := make([]byte, 16)
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(idBytes), nil
+}
+
+// Hashes a hex token received from the user, so it can be matched against the stored hash.
+func HashToken(token string) (string, error) {
+	bytes, err := hex.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+	hashedToken := sha256.Sum256(bytes)
+	return hex.EncodeToString(hashedToken[:]), nil
+}
+
+// Prefix of API keys, so a leaked key is easy to recognize, e.g. by secret scanners.
+const APIKeyPrefix = "rgk_"
+
+// Generates an API key to show once, the short prefix listed to tell keys apart, and the hash to store.
+func GenerateAPIKey() (string, string, string, error) {
+	token, hashedToken, err := GenerateHashedToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	return APIKeyPrefix + token, APIKeyPrefix + token[:8], hashedToken, nil
+}
+
+// Hashes an API key received in the X-API-Key header.
+func HashAPIKey(key string) (string, error) {
+	token, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return "", errors.New("invalid api key format")
+	}
+	return HashToken(token)
+}
+
+// Validity of API keys created without an expiry, API_KEY_EXPIRES or 90 days.
+func APIKeyDuration() (time.Duration, error) {
+	return envTokenDuration("API_KEY_EXPIRES", 90*24*time.Hour)
+}
+
+// Validity of the invitation links sent to new execs, INVITE_TOKEN_EXPIRES or 72 hours.
+func InviteTokenDuration() (time.Duration, error) {
+	return envTokenDuration("INVITE_TOKEN_EXPIRES", 72*time.Hour)
+}
+
+// Validity of magic login links, MAGIC_LINK_EXPIRES or 15 minutes.
+func MagicLinkDuration() (time.Duration, error) {
+	return envTokenDuration("MAGIC_LINK_EXPIRES", 15*time.Minute)
+}
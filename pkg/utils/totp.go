@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238), the defaults every authenticator app supports.
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// Codes of the previous and next step are accepted too, to allow for clock drift.
+	totpSkew = 1
+
+	RecoveryCodeCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Generates a 160 bit TOTP secret, base32 encoded as authenticator apps expect.
+func GenerateTOTPSecret() (string, error) {
+	secretBytes := make([]byte, 20)
+	_, err := rand.Read(secretBytes)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secretBytes), nil
+}
+
+// The otpauth:// URI to render as a QR code. The issuer is MFA_ISSUER, or RestGo.
+func TOTPProvisioningURI(accountName, secret string) string {
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "RestGo"
+	}
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer + ":" + accountName)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Checks code against secret at now. Returns the time step it matched, which the caller
+// stores so the same code can't be used twice.
+func VerifyTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	step := now.Unix() / totpPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		if hmac.Equal([]byte(totpCode(key, step+offset)), []byte(code)) {
+			return step + offset, true
+		}
+	}
+	return 0, false
+}
+
+// HOTP (RFC 4226) of counter, truncated to totpDigits.
+func totpCode(key []byte, counter int64) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%modulo)
+}
+
+// Generates single use recovery codes like "3f9a1-c07be", along with the hashes to store.
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		codeBytes := make([]byte, 5)
+		_, err := rand.Read(codeBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(codeBytes)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i], err = HashRecoveryCode(codes[i])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return codes, hashes, nil
+}
+
+// Hashes a recovery code as typed by the user, ignoring case and the dash.
+func HashRecoveryCode(code string) (string, error) {
+	return HashToken(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
+
+// Roles that must use MFA, MFA_REQUIRED_ROLES as a comma separated list, e.g. admin.
+// Unset by default, so upgrading doesn't force existing execs to enroll on their next login.
+func MFARequired(role string) bool {
+	for _, required := range strings.Split(os.Getenv("MFA_REQUIRED_ROLES"), ",") {
+		if role != "" && strings.TrimSpace(required) == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A single failing field. Index is the position of the record in a bulk request.
+type FieldError struct {
+	Index   *int   `json:"index,omitempty"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Every failing field of a request, surfaced as a 422 with the errors listed in the problem body.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	if len(v) == 1 {
+		return "Validation failed for 1 field."
+	}
+	return fmt.Sprintf("Validation failed for %d fields.", len(v))
+}
+
+func (v ValidationErrors) Unwrap() error {
+	return ErrValidation
+}
+
+// Class names are a grade from 1 to 12 followed by a section letter, e.g. 9A.
+var classPattern = regexp.MustCompile(`^(?:[1-9]|1[0-2])[A-Z]$`)
+
+// Validates a struct using its `validate` tags, e.g. `validate:"required,email,max=255"`.
+func ValidateStruct(model interface{}) error {
+	errs := validateStruct(model, nil)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validates every item of a slice of structs, tagging each error with the item index.
+func ValidateItems(items interface{}) error {
+	var errs ValidationErrors
+	itemsVal := reflect.ValueOf(items)
+	for i := 0; i < itemsVal.Len(); i++ {
+		index := i
+		errs = append(errs, validateStruct(itemsVal.Index(i).Interface(), &index)...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validates a PATCH body against the tags of model. Only the keys present are checked,
+// so required only rejects blanking a field. Unknown keys and wrong types are errors too.
+func ValidateUpdates(model interface{}, updates map[string]interface{}) error {
+	errs := validateUpdates(model, updates, nil)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Same as ValidateUpdates for a bulk PATCH, each entry must also carry its "id".
+func ValidateBulkUpdates(model interface{}, updates []map[string]interface{}) error {
+	var errs ValidationErrors
+	for i, update := range updates {
+		index := i
+		if _, ok := update["id"]; !ok {
+			errs = append(errs, FieldError{Index: &index, Field: "id", Message: "is required"})
+		}
+		errs = append(errs, validateUpdates(model, update, &index)...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(model interface{}, index *int) ValidationErrors {
+	var errs ValidationErrors
+	modelVal := reflect.ValueOf(model)
+	modelType := modelVal.Type()
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		rules := field.Tag.Get("validate")
+		if rules == "" {
+			continue
+		}
+		jsonField := strings.TrimSuffix(field.Tag.Get("json"), ",omitempty")
+		message := checkRules(modelVal.Field(i).Interface(), rules, false)
+		if message != "" {
+			errs = append(errs, FieldError{Index: index, Field: jsonField, Message: message})
+		}
+	}
+	return errs
+}
+
+func validateUpdates(model interface{}, updates map[string]interface{}, index *int) ValidationErrors {
+	var errs ValidationErrors
+	modelType := reflect.TypeOf(model)
+
+	// Sorted keys keep the error order stable between requests.
+	keys := make([]string, 0, len(updates))
+	for key := range updates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := updates[key]
+		if key == "id" {
+			continue
+		}
+
+		field, ok := fieldByJSONName(modelType, key)
+		if !ok {
+			errs = append(errs, FieldError{Index: index, Field: key, Message: "is not an allowed field"})
+			continue
+		}
+		if value == nil || !reflect.TypeOf(value).ConvertibleTo(field.Type) || (field.Type.Kind() == reflect.String && reflect.TypeOf(value).Kind() != reflect.String) {
+			errs = append(errs, FieldError{Index: index, Field: key, Message: fmt.Sprintf("must be of type %s", field.Type.Kind())})
+			continue
+		}
+
+		message := checkRules(reflect.ValueOf(value).Convert(field.Type).Interface(), field.Tag.Get("validate"), true)
+		if message != "" {
+			errs = append(errs, FieldError{Index: index, Field: key, Message: message})
+		}
+	}
+	return errs
+}
+
+func fieldByJSONName(modelType reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		if strings.TrimSuffix(field.Tag.Get("json"), ",omitempty") == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Returns the message of the first failing rule, or "" if value passes all of them.
+func checkRules(value interface{}, rules string, partial bool) string {
+	str, isString := value.(string)
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "":
+			continue
+		case "required":
+			if isString && strings.TrimSpace(str) == "" || !isString && reflect.ValueOf(value).IsZero() && !partial {
+				return "is required"
+			}
+		case "readonly":
+			// Set by the server, so it can't be patched. Full bodies may echo it back, it is ignored.
+			if partial {
+				return "is read-only"
+			}
+		}
+
+		// Remaining rules only apply to non empty strings.
+		if !isString || str == "" {
+			continue
+		}
+		switch name {
+		case "email":
+			address, err := mail.ParseAddress(str)
+			if err != nil || address.Address != str {
+				return "must be a valid email address"
+			}
+		case "max":
+			max, _ := strconv.Atoi(param)
+			if utf8.RuneCountInString(str) > max {
+				return fmt.Sprintf("must be at most %d characters", max)
+			}
+		case "min":
+			min, _ := strconv.Atoi(param)
+			if utf8.RuneCountInString(str) < min {
+				return fmt.Sprintf("must be at least %d characters", min)
+			}
+		case "oneof":
+			allowed := strings.Fields(param)
+			found := false
+			for _, a := range allowed {
+				if str == a {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", "))
+			}
+		case "class":
+			if !classPattern.MatchString(str) {
+				return "must be a grade from 1 to 12 followed by a section letter, e.g. 9A"
+			}
+		}
+	}
+	return ""
+}
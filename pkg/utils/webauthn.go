@@ -0,0 +1,351 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// Relying party settings of the passkey ceremonies. WEBAUTHN_RP_ID is the domain passkeys are
+// bound to (localhost), WEBAUTHN_RP_NAME the name authenticators show (RestGo) and
+// WEBAUTHN_ORIGINS the comma separated origins the browser may run them from (https://localhost:3000).
+type WebAuthnConfig struct {
+	RPID    string
+	RPName  string
+	Origins []string
+	// How long a ceremony may take, WEBAUTHN_TIMEOUT (5m).
+	Timeout time.Duration
+}
+
+func CurrentWebAuthnConfig() WebAuthnConfig {
+	config := WebAuthnConfig{
+		RPID:    os.Getenv("WEBAUTHN_RP_ID"),
+		RPName:  os.Getenv("WEBAUTHN_RP_NAME"),
+		Timeout: EnvDuration("WEBAUTHN_TIMEOUT", 5*time.Minute, time.Second),
+	}
+	if config.RPID == "" {
+		config.RPID = "localhost"
+	}
+	if config.RPName == "" {
+		config.RPName = "RestGo"
+	}
+	for _, origin := range strings.Split(os.Getenv("WEBAUTHN_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			config.Origins = append(config.Origins, origin)
+		}
+	}
+	if len(config.Origins) == 0 {
+		config.Origins = []string{"https://localhost:3000"}
+	}
+	return config
+}
+
+// COSE algorithms of the supported passkeys, in the order they are offered.
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+var SupportedCOSEAlgs = []int{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+// Flags of the authenticator data.
+const (
+	authDataUserPresent  = 0x01
+	authDataUserVerified = 0x04
+	authDataAttested     = 0x40
+	authDataExtensions   = 0x80
+)
+
+// The clientDataJSON of a ceremony, built by the browser.
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// The authenticator data of a ceremony. The credential fields are only set on registration.
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	// COSE_Key of the credential.
+	PublicKey []byte
+}
+
+// Whether the authenticator checked the user, e.g. with a PIN or a fingerprint, on top of
+// their presence. Such a passkey is a second factor on its own.
+func (a AuthenticatorData) UserVerified() bool {
+	return a.Flags&authDataUserVerified != 0
+}
+
+// AAGUID in the usual UUID format, identifying the authenticator model.
+func (a AuthenticatorData) AAGUIDString() string {
+	if len(a.AAGUID) != 16 {
+		return ""
+	}
+	id := hex.EncodeToString(a.AAGUID)
+	return id[:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:]
+}
+
+// Generates the random challenge of a ceremony, base64url encoded like in clientDataJSON.
+func GenerateWebAuthnChallenge() (string, error) {
+	challenge := make([]byte, 32)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(challenge), nil
+}
+
+// Decodes the base64url fields of WebAuthn JSON, with or without padding.
+func DecodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// Reads clientDataJSON, mostly to find the challenge to look up. Verify* check the rest.
+func ParseClientData(clientDataJSON []byte) (ClientData, error) {
+	var clientData ClientData
+	err := json.Unmarshal(clientDataJSON, &clientData)
+	if err != nil {
+		return ClientData{}, err
+	}
+	if clientData.Challenge == "" {
+		return ClientData{}, errors.New("client data has no challenge")
+	}
+	return clientData, nil
+}
+
+func ParseAuthenticatorData(data []byte) (AuthenticatorData, error) {
+	if len(data) < 37 {
+		return AuthenticatorData{}, errors.New("authenticator data is too short")
+	}
+	authData := AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.Flags&authDataAttested != 0 {
+		if len(rest) < 18 {
+			return AuthenticatorData{}, errors.New("attested credential data is too short")
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength > 1023 || len(rest) < idLength {
+			return AuthenticatorData{}, errors.New("invalid credential id length")
+		}
+		authData.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// The key is followed by the extensions, its CBOR encoding tells where it ends.
+		_, size, err := decodeCBOR(rest)
+		if err != nil {
+			return AuthenticatorData{}, fmt.Errorf("invalid credential public key: %w", err)
+		}
+		authData.PublicKey = rest[:size]
+		rest = rest[size:]
+	}
+	if authData.Flags&authDataExtensions != 0 {
+		_, size, err := decodeCBOR(rest)
+		if err != nil {
+			return AuthenticatorData{}, fmt.Errorf("invalid extensions: %w", err)
+		}
+		rest = rest[size:]
+	}
+	if len(rest) > 0 {
+		return AuthenticatorData{}, errors.New("unexpected data after the authenticator data")
+	}
+	return authData, nil
+}
+
+// Checks the response of navigator.credentials.create() against the challenge it answers and
+// returns the new credential. Attestation statements aren't verified: the options ask for
+// "none", since any authenticator model is accepted.
+func (c WebAuthnConfig) VerifyRegistration(clientDataJSON []byte, attestationObject []byte, challenge string) (AuthenticatorData, error) {
+	err := c.verifyClientData(clientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return AuthenticatorData{}, fmt.Errorf("invalid attestation object: %w", err)
+	}
+	attestation, _ := decoded.(map[interface{}]interface{})
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return AuthenticatorData{}, errors.New("attestation object has no authenticator data")
+	}
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+	err = c.verifyAuthenticatorData(authData)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+	if authData.CredentialID == nil {
+		return AuthenticatorData{}, errors.New("registration has no attested credential")
+	}
+	_, _, err = ParseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+	return authData, nil
+}
+
+// Checks the response of navigator.credentials.get(), signed by the credential with the
+// given COSE_Key. The sign counter is left to the caller, see SignCountValid.
+func (c WebAuthnConfig) VerifyAssertion(clientDataJSON []byte, rawAuthData []byte, signature []byte, publicKey []byte, challenge string) (AuthenticatorData, error) {
+	err := c.verifyClientData(clientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+	err = c.verifyAuthenticatorData(authData)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+
+	// The signature covers the authenticator data followed by the hash of the client data.
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	err = verifyCOSESignature(publicKey, message, signature)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+	return authData, nil
+}
+
+// Authenticators that count signatures must report a higher count each time, a lower or equal
+// one means the credential may have been cloned. Those that don't count always report 0.
+func SignCountValid(stored uint32, received uint32) bool {
+	return received > stored || (stored == 0 && received == 0)
+}
+
+func (c WebAuthnConfig) verifyClientData(clientDataJSON []byte, ceremony string, challenge string) error {
+	clientData, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+	if clientData.Type != ceremony {
+		return fmt.Errorf("client data type is %q, expected %q", clientData.Type, ceremony)
+	}
+	if subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(challenge)) != 1 {
+		return errors.New("challenge mismatch")
+	}
+	if clientData.CrossOrigin {
+		return errors.New("cross-origin ceremonies are not allowed")
+	}
+	for _, origin := range c.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q is not allowed", clientData.Origin)
+}
+
+func (c WebAuthnConfig) verifyAuthenticatorData(authData AuthenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(authData.RPIDHash, rpIDHash[:]) {
+		return errors.New("credential is for another relying party")
+	}
+	if authData.Flags&authDataUserPresent == 0 {
+		return errors.New("user presence was not confirmed")
+	}
+	return nil
+}
+
+// Converts a COSE_Key to the public key it holds, and returns its COSE algorithm.
+func ParseCOSEKey(coseKey []byte) (crypto.PublicKey, int, error) {
+	decoded, _, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid COSE key: %w", err)
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("invalid COSE key")
+	}
+	// Labels of RFC 9053: 1 kty, 3 alg, -1 crv or n, -2 x or e, -3 y.
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid P-256 COSE key")
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, 0, errors.New("COSE key is not on the P-256 curve")
+		}
+		return publicKey, COSEAlgES256, nil
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid Ed25519 COSE key")
+		}
+		return ed25519.PublicKey(x), COSEAlgEdDSA, nil
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RSA COSE key")
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, COSEAlgRS256, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported COSE key type %d with algorithm %d", kty, alg)
+}
+
+func verifyCOSESignature(coseKey []byte, message []byte, signature []byte) error {
+	publicKey, alg, err := ParseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(message)
+	switch alg {
+	case COSEAlgES256:
+		if ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature) {
+			return nil
+		}
+	case COSEAlgEdDSA:
+		if ed25519.Verify(publicKey.(ed25519.PublicKey), message, signature) {
+			return nil
+		}
+	case COSEAlgRS256:
+		if rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	}
+	return errors.New("invalid signature")
+}
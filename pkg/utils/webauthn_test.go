@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Minimal CBOR encoding, enough to build what an authenticator sends.
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 1<<8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n < 1<<16:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	}
+	head := []byte{major<<5 | 26, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(head[1:], uint32(n))
+	return head
+}
+
+func cborInt(i int64) []byte {
+	if i < 0 {
+		return cborHead(1, uint64(-1-i))
+	}
+	return cborHead(0, uint64(i))
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, uint64(len(b))), b...) }
+func cborText(s string) []byte  { return append(cborHead(3, uint64(len(s))), s...) }
+
+// Map of the given encoded keys and values, in order.
+func cborMap(pairs ...[]byte) []byte {
+	encoded := cborHead(5, uint64(len(pairs)/2))
+	for _, item := range pairs {
+		encoded = append(encoded, item...)
+	}
+	return encoded
+}
+
+// A software authenticator holding one ES256 or EdDSA credential.
+type testAuthenticator struct {
+	t            *testing.T
+	alg          int
+	ecKey        *ecdsa.PrivateKey
+	edKey        ed25519.PrivateKey
+	credentialID []byte
+	rpID         string
+	origin       string
+	flags        byte
+	signCount    uint32
+}
+
+func newTestAuthenticator(t *testing.T, alg int) *testAuthenticator {
+	a := &testAuthenticator{
+		t:            t,
+		alg:          alg,
+		credentialID: []byte("test-credential-" + t.Name()),
+		rpID:         "localhost",
+		origin:       "https://localhost:3000",
+		flags:        authDataUserPresent | authDataUserVerified,
+	}
+	var err error
+	switch alg {
+	case COSEAlgES256:
+		a.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case COSEAlgEdDSA:
+		_, a.edKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unsupported test algorithm %d", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func (a *testAuthenticator) coseKey() []byte {
+	if a.alg == COSEAlgEdDSA {
+		return cborMap(
+			cborInt(1), cborInt(1),
+			cborInt(3), cborInt(COSEAlgEdDSA),
+			cborInt(-1), cborInt(6),
+			cborInt(-2), cborBytes(a.edKey.Public().(ed25519.PublicKey)),
+		)
+	}
+	return cborMap(
+		cborInt(1), cborInt(2),
+		cborInt(3), cborInt(COSEAlgES256),
+		cborInt(-1), cborInt(1),
+		cborInt(-2), cborBytes(a.ecKey.X.FillBytes(make([]byte, 32))),
+		cborInt(-3), cborBytes(a.ecKey.Y.FillBytes(make([]byte, 32))),
+	)
+}
+
+func (a *testAuthenticator) authData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := a.flags
+	if attested {
+		flags |= authDataAttested
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+func (a *testAuthenticator) clientData(ceremony string, challenge string) []byte {
+	clientDataJSON, err := json.Marshal(ClientData{Type: ceremony, Challenge: challenge, Origin: a.origin})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return clientDataJSON
+}
+
+// The clientDataJSON and attestationObject of navigator.credentials.create().
+func (a *testAuthenticator) register(challenge string) ([]byte, []byte) {
+	attestationObject := cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(a.authData(true)),
+	)
+	return a.clientData("webauthn.create", challenge), attestationObject
+}
+
+// The clientDataJSON, authenticatorData and signature of navigator.credentials.get().
+func (a *testAuthenticator) login(challenge string) ([]byte, []byte, []byte) {
+	a.signCount++
+	clientDataJSON := a.clientData("webauthn.get", challenge)
+	authData := a.authData(false)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte{}, authData...), clientDataHash[:]...)
+
+	var signature []byte
+	if a.alg == COSEAlgEdDSA {
+		signature = ed25519.Sign(a.edKey, message)
+	} else {
+		digest := sha256.Sum256(message)
+		var err error
+		signature, err = ecdsa.SignASN1(rand.Reader, a.ecKey, digest[:])
+		if err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	return clientDataJSON, authData, signature
+}
+
+func testWebAuthnConfig() WebAuthnConfig {
+	return WebAuthnConfig{RPID: "localhost", RPName: "RestGo", Origins: []string{"https://localhost:3000"}}
+}
+
+func testChallenge(t *testing.T) string {
+	challenge, err := GenerateWebAuthnChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	for _, alg := range []int{COSEAlgES256, COSEAlgEdDSA} {
+		a := newTestAuthenticator(t, alg)
+		config := testWebAuthnConfig()
+
+		challenge := testChallenge(t)
+		clientDataJSON, attestationObject := a.register(challenge)
+		registered, err := config.VerifyRegistration(clientDataJSON, attestationObject, challenge)
+		if err != nil {
+			t.Fatalf("alg %d: registration failed: %v", alg, err)
+		}
+		if !bytes.Equal(registered.CredentialID, a.credentialID) {
+			t.Errorf("alg %d: credential id = %q, want %q", alg, registered.CredentialID, a.credentialID)
+		}
+		if !registered.UserVerified() {
+			t.Errorf("alg %d: user verified flag lost", alg)
+		}
+		if _, keyAlg, err := ParseCOSEKey(registered.PublicKey); err != nil || keyAlg != alg {
+			t.Errorf("alg %d: stored key parses as alg %d: %v", alg, keyAlg, err)
+		}
+
+		storedCount := registered.SignCount
+		for i := 0; i < 2; i++ {
+			challenge = testChallenge(t)
+			clientDataJSON, authData, signature := a.login(challenge)
+			asserted, err := config.VerifyAssertion(clientDataJSON, authData, signature, registered.PublicKey, challenge)
+			if err != nil {
+				t.Fatalf("alg %d: login %d failed: %v", alg, i, err)
+			}
+			if !SignCountValid(storedCount, asserted.SignCount) {
+				t.Errorf("alg %d: sign count %d after %d rejected", alg, asserted.SignCount, storedCount)
+			}
+			storedCount = asserted.SignCount
+		}
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(a *testAuthenticator)
+		want   string
+	}{
+		{"wrong origin", func(a *testAuthenticator) { a.origin = "https://evil.example" }, "origin"},
+		{"rp id hash mismatch", func(a *testAuthenticator) { a.rpID = "evil.example" }, "relying party"},
+		{"user not present", func(a *testAuthenticator) { a.flags = authDataUserVerified }, "presence"},
+	}
+	for _, test := range tests {
+		a := newTestAuthenticator(t, COSEAlgES256)
+		test.change(a)
+		challenge := testChallenge(t)
+		clientDataJSON, attestationObject := a.register(challenge)
+		_, err := testWebAuthnConfig().VerifyRegistration(clientDataJSON, attestationObject, challenge)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: err = %v, want one about %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	config := testWebAuthnConfig()
+	a := newTestAuthenticator(t, COSEAlgES256)
+	challenge := testChallenge(t)
+	clientDataJSON, attestationObject := a.register(challenge)
+	registered, err := config.VerifyRegistration(clientDataJSON, attestationObject, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(a *testAuthenticator)
+		want   string
+	}{
+		{"wrong origin", func(a *testAuthenticator) { a.origin = "https://evil.example" }, "origin"},
+		{"rp id hash mismatch", func(a *testAuthenticator) { a.rpID = "evil.example" }, "relying party"},
+		{"user not present", func(a *testAuthenticator) { a.flags = 0 }, "presence"},
+		{"signed by another key", func(a *testAuthenticator) {
+			a.ecKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}, "signature"},
+	}
+	for _, test := range tests {
+		clone := *a
+		test.change(&clone)
+		challenge := testChallenge(t)
+		clientDataJSON, authData, signature := clone.login(challenge)
+		_, err := config.VerifyAssertion(clientDataJSON, authData, signature, registered.PublicKey, challenge)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: err = %v, want one about %q", test.name, err, test.want)
+		}
+	}
+
+	// A response captured for an earlier challenge doesn't answer the current one.
+	oldChallenge := testChallenge(t)
+	clientDataJSON, authData, signature := a.login(oldChallenge)
+	_, err = config.VerifyAssertion(clientDataJSON, authData, signature, registered.PublicKey, testChallenge(t))
+	if err == nil || !strings.Contains(err.Error(), "challenge") {
+		t.Errorf("replayed response: err = %v, want a challenge mismatch", err)
+	}
+}
+
+func TestSignCountValid(t *testing.T) {
+	tests := []struct {
+		stored, received uint32
+		want             bool
+	}{
+		{0, 0, true},
+		{0, 1, true},
+		{5, 6, true},
+		{5, 5, false},
+		{5, 4, false},
+		{5, 0, false},
+	}
+	for _, test := range tests {
+		if got := SignCountValid(test.stored, test.received); got != test.want {
+			t.Errorf("SignCountValid(%d, %d) = %v, want %v", test.stored, test.received, got, test.want)
+		}
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	deep := bytes.Repeat([]byte{0x81}, maxCBORDepth+2)
+	deep = append(deep, 0x00)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "unexpected end"},
+		{"truncated argument", []byte{0x19, 0x01}, "unexpected end"},
+		{"truncated byte string", []byte{0x45, 0x01, 0x02}, "unexpected end"},
+		{"truncated array", []byte{0x82, 0x01}, "unexpected end"},
+		{"truncated map", []byte{0xa1, 0x01}, "unexpected end"},
+		{"huge length", []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "unexpected end"},
+		{"indefinite byte string", []byte{0x5f, 0x41, 0x00, 0xff}, "indefinite"},
+		{"indefinite array", []byte{0x9f, 0x01, 0xff}, "indefinite"},
+		{"indefinite map", []byte{0xbf, 0x01, 0x02, 0xff}, "indefinite"},
+		{"duplicate map key", cborMap(cborInt(1), cborInt(2), cborInt(1), cborInt(3)), "duplicate"},
+		{"unsupported map key", cborMap(cborBytes([]byte{1}), cborInt(2)), "map key"},
+		{"nested too deeply", deep, "nested too deeply"},
+	}
+	for _, test := range tests {
+		_, _, err := decodeCBORItem(test.data, 0)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: err = %v, want one about %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	data := cborMap(
+		cborText("fmt"), cborText("none"),
+		cborInt(-7), cborBytes([]byte{1, 2, 3}),
+		cborInt(1000), append(cborHead(4, 2), 0xf5, 0xf6),
+	)
+	data = append(data, 0xff) // trailing data is left to the caller
+
+	decoded, size, err := decodeCBOR(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != len(data)-1 {
+		t.Errorf("size = %d, want %d", size, len(data)-1)
+	}
+	items := decoded.(map[interface{}]interface{})
+	if items["fmt"] != "none" || !bytes.Equal(items[int64(-7)].([]byte), []byte{1, 2, 3}) {
+		t.Errorf("decoded = %v", items)
+	}
+	if array := items[int64(1000)].([]interface{}); len(array) != 2 || array[0] != true || array[1] != nil {
+		t.Errorf("decoded array = %v", array)
+	}
+}